@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Clipboard
+//
+// Killed and copied text is also sent to the system clipboard, and a yank
+// first picks up text copied by other applications. When xclip or wl-copy is
+// available the clipboard is read and written with them. Otherwise the text is
+// sent with the OSC 52 escape sequence, which the terminal forwards to the
+// desktop clipboard even over SSH, but which can't be read back.
+//
+// The commands run in the event loop, so one which hangs is killed after
+// clipboardTimeout, and the clipboard is left alone for that key.
+
+var clipboardTimeout = time.Second
+
+type Clipboard interface {
+	Copy(text []rune) error
+
+	// Paste returns the text in the clipboard, or nil if it can't be read.
+	Paste() ([]rune, error)
+}
+
+// osc52Clipboard writes the text to the terminal as an OSC 52 sequence.
+type osc52Clipboard struct {
+	write func(b []byte)
+}
+
+func (c *osc52Clipboard) Copy(text []rune) error {
+	seq := "\033]52;c;" + base64.StdEncoding.EncodeToString(encodeRunes(text)) + "\a"
+	c.write([]byte(seq))
+	return nil
+}
+
+func (c *osc52Clipboard) Paste() ([]rune, error) {
+	return nil, nil
+}
+
+// commandClipboard runs external commands, such as xclip, to copy and paste.
+type commandClipboard struct {
+	copyCmd  []string
+	pasteCmd []string
+}
+
+func (c *commandClipboard) Copy(text []rune) error {
+	ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.copyCmd[0], c.copyCmd[1:]...)
+	cmd.Stdin = bytes.NewReader(encodeRunes(text))
+	return cmd.Run()
+}
+
+func (c *commandClipboard) Paste() ([]rune, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, c.pasteCmd[0], c.pasteCmd[1:]...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return decodeBytes(out), nil
+}
+
+// findClipboardCommands returns the commands for the running display server,
+// or nil if none is installed.
+func findClipboardCommands() *commandClipboard {
+	installed := func(name string) bool {
+		_, err := exec.LookPath(name)
+		return err == nil
+	}
+
+	if os.Getenv("WAYLAND_DISPLAY") != "" && installed("wl-copy") && installed("wl-paste") {
+		return &commandClipboard{
+			copyCmd:  []string{"wl-copy"},
+			pasteCmd: []string{"wl-paste", "--no-newline"},
+		}
+	}
+
+	if os.Getenv("DISPLAY") != "" && installed("xclip") {
+		return &commandClipboard{
+			copyCmd:  []string{"xclip", "-in", "-selection", "clipboard"},
+			pasteCmd: []string{"xclip", "-out", "-selection", "clipboard"},
+		}
+	}
+
+	return nil
+}
+
+func newClipboard(write func(b []byte)) Clipboard {
+	if c := findClipboardCommands(); c != nil {
+		return c
+	}
+	return &osc52Clipboard{write: write}
+}
+
+// copyToClipboard sends text to the system clipboard, if there is one.
+func (e *Editor) copyToClipboard(text []rune) {
+	if e.clipboard == nil {
+		return
+	}
+
+	if err := e.clipboard.Copy(text); err != nil {
+		e.debugPrint("clipboard:", err)
+	}
+}
+
+// pasteFromClipboard returns the text in the system clipboard, or nil.
+func (e *Editor) pasteFromClipboard() []rune {
+	if e.clipboard == nil {
+		return nil
+	}
+
+	text, err := e.clipboard.Paste()
+	if err != nil {
+		e.debugPrint("clipboard:", err)
+		return nil
+	}
+	return text
+}
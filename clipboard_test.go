@@ -0,0 +1,96 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeClipboard struct {
+	text []rune
+}
+
+func (c *fakeClipboard) Copy(text []rune) error {
+	c.text = append([]rune{}, text...)
+	return nil
+}
+
+func (c *fakeClipboard) Paste() ([]rune, error) {
+	return c.text, nil
+}
+
+func TestOsc52Clipboard(t *testing.T) {
+	var out []byte
+	c := &osc52Clipboard{write: func(b []byte) { out = append(out, b...) }}
+
+	assert.NoError(t, c.Copy([]rune("héllo\n")))
+	assert.Equal(t, "\033]52;c;aMOpbGxvCg==\a", string(out))
+
+	text, err := c.Paste()
+	assert.NoError(t, err)
+	assert.Nil(t, text)
+}
+
+func TestCommandClipboard(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "clipboard")
+	c := &commandClipboard{
+		copyCmd:  []string{"sh", "-c", "cat > " + file},
+		pasteCmd: []string{"cat", file},
+	}
+
+	assert.NoError(t, c.Copy([]rune("あいう\nabc")))
+	text, err := c.Paste()
+	assert.NoError(t, err)
+	assert.Equal(t, "あいう\nabc", string(text))
+
+	c.pasteCmd = []string{"false"}
+	_, err = c.Paste()
+	assert.Error(t, err)
+}
+
+func TestCommandClipboard_Timeout(t *testing.T) {
+	defer func(d time.Duration) { clipboardTimeout = d }(clipboardTimeout)
+	clipboardTimeout = 100 * time.Millisecond
+
+	c := &commandClipboard{
+		copyCmd:  []string{"sleep", "10"},
+		pasteCmd: []string{"sleep", "10"},
+	}
+
+	start := time.Now()
+	assert.Error(t, c.Copy([]rune("abc")))
+	_, err := c.Paste()
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestKill_CopiesToClipboard(t *testing.T) {
+	e := makeEditor("")
+	c := &fakeClipboard{}
+	e.clipboard = c
+
+	e.lastKey = 'x'
+	e.kill([]rune("foo"), false)
+	e.lastKey = ControlK
+	e.kill([]rune("bar"), false)
+	assert.Equal(t, "foobar", string(c.text))
+}
+
+func TestYank_PastesFromClipboard(t *testing.T) {
+	e := makeEditor("abc\n")
+	attachScreen(e, 80, 12)
+	c := &fakeClipboard{}
+	e.clipboard = c
+
+	e.kill([]rune("foo"), false)
+	c.text = []rune("bar")
+
+	e.yank()
+	assert.Equal(t, "barabc\n", e.rows[0].chars.RunesString())
+	assert.Equal(t, 2, len(e.killRing.entries))
+
+	// The same text isn't pushed twice.
+	e.yank()
+	assert.Equal(t, 2, len(e.killRing.entries))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"unicode/utf8"
+)
+
+// Bytes which are not part of a valid UTF-8 sequence are kept as runes in the
+// low surrogate range U+DC80..U+DCFF. A valid UTF-8 decoding never produces a
+// surrogate, so saveFile can write those bytes back exactly as they were read.
+const (
+	rawByteMin rune = 0xDC80
+	rawByteMax rune = 0xDCFF
+)
+
+// utf8BOM starts the files of the "UTF-8 BOM" encoding.
+const utf8BOM = "\xef\xbb\xbf"
+
+func isRawByte(r rune) bool {
+	return r >= rawByteMin && r <= rawByteMax
+}
+
+// decodeRune decodes the first rune of b.
+// An invalid byte is returned as a raw byte rune of size 1.
+func decodeRune(b []byte) (rune, int) {
+	r, size := utf8.DecodeRune(b)
+	if r == utf8.RuneError && size <= 1 {
+		if len(b) == 0 {
+			return utf8.RuneError, 0
+		}
+		return rawByteMin + rune(b[0]) - 0x80, 1
+	}
+	return r, size
+}
+
+func decodeBytes(b []byte) []rune {
+	runes := make([]rune, 0, len(b))
+	for len(b) > 0 {
+		r, size := decodeRune(b)
+		runes = append(runes, r)
+		b = b[size:]
+	}
+	return runes
+}
+
+func encodeRunes(runes []rune) []byte {
+	b := make([]byte, 0, len(runes))
+	var tmp [utf8.UTFMax]byte
+	for _, r := range runes {
+		if isRawByte(r) {
+			b = append(b, byte(r-rawByteMin+0x80))
+			continue
+		}
+		n := utf8.EncodeRune(tmp[:], r)
+		b = append(b, tmp[:n]...)
+	}
+	return b
+}
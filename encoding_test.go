@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeBytes_UTF8(t *testing.T) {
+	runes := decodeBytes([]byte("aあ😀é"))
+	assert.Equal(t, []rune{'a', 'あ', '😀', 'é'}, runes)
+}
+
+func TestDecodeBytes_InvalidBytes(t *testing.T) {
+	runes := decodeBytes([]byte{'a', 0xff, 0xe3, 0x81, 'b'})
+	assert.Equal(t, 5, len(runes))
+	assert.Equal(t, 'a', runes[0])
+	assert.True(t, isRawByte(runes[1]))
+	assert.True(t, isRawByte(runes[2]))
+	assert.True(t, isRawByte(runes[3]))
+	assert.Equal(t, 'b', runes[4])
+}
+
+func TestEncodeRunes_RoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("hello, 世界\n"),
+		{0xff, 0xfe, 'x', 0xc3},
+		{0xed, 0xb2, 0x80}, // an encoded surrogate is invalid UTF-8, too
+	}
+
+	for _, b := range inputs {
+		assert.Equal(t, b, encodeRunes(decodeBytes(b)))
+	}
+}
+
+func TestLoadFile_SaveFile_UTF8(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mille")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	content := []byte("こんにちは\nfunc 世界() {}\n\xff\xfe\n")
+	path := filepath.Join(dir, "test.txt")
+	assert.NoError(t, ioutil.WriteFile(path, content, 0644))
+
+	e := loadFile(path)
+	assert.Equal(t, 4, e.n)
+	assert.Equal(t, 5, e.rows[0].visibleLen())
+	assert.Equal(t, 'こ', e.rows[0].chars.At(0))
+
+	assert.NoError(t, saveFile(path, e.rows, "UTF-8", "LF"))
+	saved, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, content, saved)
+}
+
+func TestLoadFile_SaveFile_CRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crlf.txt")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("ab\r\ncd\r\n"), 0644))
+
+	s := newMemoryScreen(80, 6)
+	e := newEditor(path, false, s)
+	assert.Equal(t, "CRLF", e.eol)
+	assert.Equal(t, 2, e.rows[0].visibleLen())
+
+	for _, r := range []rune{ControlE, Enter, 'x', ControlS} {
+		e.interpretEvent(keyEvent{key: r})
+	}
+	assert.Contains(t, s.RowText(4), "Ln 2/3, Col 2")
+
+	saved, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab\r\nx\r\ncd\r\n", string(saved))
+}
+
+func TestLoadFile_SaveFile_BOM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bom.txt")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("\xef\xbb\xbfabc\n"), 0644))
+
+	s := newMemoryScreen(80, 6)
+	e := newEditor(path, false, s)
+	assert.Equal(t, "UTF-8 BOM", e.encoding)
+	assert.Equal(t, "abc\n", e.rows[0].chars.RunesString())
+
+	for _, r := range []rune{ControlE, 'd', ControlS} {
+		e.interpretEvent(keyEvent{key: r})
+	}
+	assert.Equal(t, "abcd", s.RowText(0)[:4])
+	assert.Contains(t, s.RowText(4), "Col 5")
+
+	saved, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "\xef\xbb\xbfabcd\n", string(saved))
+}
@@ -77,17 +77,18 @@ func (g *GapTable) InsertAt(index int, r rune) {
 			g.endPieceIndex -= 1
 		} else {
 			// e.g.) Insert G at #
-			// before: [A, B, C, x, x, x, D, #, E, F]
+			// before: [A, B, C, x, x, x, D, E, F]  (ABCD#EF)
 			//                   ^     ^
 			//                   s     e
-			// after:  [A, B, C, D, x, x, D, G, E, F]
-			//                      ^     ^
-			//                      s     e
-			copyTarget := g.array[g.endPieceIndex+1 : index+g.endPieceIndex-g.startPieceIndex+2]
+			// after:  [A, B, C, D, G, x, D, E, F]
+			//                         ^  ^
+			//                         s  e
+			copyTarget := g.array[g.endPieceIndex+1 : index+g.endPieceIndex-g.startPieceIndex+1]
 			n := copy(g.array[g.startPieceIndex:g.startPieceIndex+len(copyTarget)], copyTarget)
-			g.SetAt(index, r)
 			g.startPieceIndex += n
-			g.endPieceIndex += n - 1
+			g.endPieceIndex += n
+			g.array[g.startPieceIndex] = r
+			g.startPieceIndex += 1
 		}
 	} else {
 		// e.g.) Insert G at #
@@ -141,11 +142,13 @@ func (g *GapTable) VisibleLen() int {
 	return g.Len() - g.invisibleRuneCount
 }
 
+// Runes returns a copy of the runes, so that it never overwrites the array.
 func (g *GapTable) Runes() []rune {
-	return append(g.array[:g.startPieceIndex], g.array[g.endPieceIndex+1:]...)
+	runes := make([]rune, 0, g.Len())
+	runes = append(runes, g.array[:g.startPieceIndex]...)
+	return append(runes, g.array[g.endPieceIndex+1:]...)
 }
 
 func (g *GapTable) RunesString() string {
-	runes := append(g.array[:g.startPieceIndex], g.array[g.endPieceIndex+1:]...)
-	return string(runes)
+	return string(g.Runes())
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/stretchr/testify/assert"
+	"math/rand"
 	"testing"
 )
 
@@ -79,25 +80,53 @@ func TestGapTable_Insert_Complex(t *testing.T) {
 	assert.Equal(t, 3, g.startPieceIndex)
 	assert.Equal(t, 5, g.endPieceIndex)
 
+	// Inserting after the gap moves the runes before the index in front of it,
+	// so f goes between a and d: bceafd.
 	g.InsertAt(4, r(102))
 	assert.Equal(t, 6, g.Len())
 	assert.Equal(t, 5, g.startPieceIndex)
 	assert.Equal(t, 6, g.endPieceIndex)
-	assert.Equal(t, []rune{r(98), r(99), r(101), r(97), r(100), r(0), r(97), r(102)}, g.array)
+	assert.Equal(t, []rune{r(98), r(99), r(101), r(97), r(102), r(0), r(97), r(100)}, g.array)
 
 	g.InsertAt(7, r(103))
 	assert.Equal(t, 7, g.Len())
 	assert.Equal(t, 5, g.startPieceIndex)
 	assert.Equal(t, 5, g.endPieceIndex)
-	assert.Equal(t, []rune{r(98), r(99), r(101), r(97), r(100), r(0), r(102), r(103)}, g.array)
+	assert.Equal(t, []rune{r(98), r(99), r(101), r(97), r(102), r(0), r(100), r(103)}, g.array)
 
 	assert.Equal(t, g.At(0), r(98))
 	assert.Equal(t, g.At(1), r(99))
 	assert.Equal(t, g.At(2), r(101))
 	assert.Equal(t, g.At(3), r(97))
-	assert.Equal(t, g.At(4), r(100))
-	assert.Equal(t, g.At(5), r(102))
+	assert.Equal(t, g.At(4), r(102))
+	assert.Equal(t, g.At(5), r(100))
 	assert.Equal(t, g.At(6), r(103))
+	assert.Equal(t, "bceafdg", g.RunesString())
+}
+
+// Compare random insertions and deletions with a plain slice.
+func TestGapTable_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		g := NewGapTable(4)
+		var expected []rune
+
+		for j := 0; j < 100; j++ {
+			if len(expected) > 0 && rnd.Intn(3) == 0 {
+				index := rnd.Intn(len(expected))
+				g.DeleteAt(index)
+				expected = append(expected[:index], expected[index+1:]...)
+			} else {
+				index := rnd.Intn(len(expected) + 1)
+				ch := rune('a' + rnd.Intn(26))
+				g.InsertAt(index, ch)
+				expected = append(expected[:index], append([]rune{ch}, expected[index:]...)...)
+			}
+
+			assert.Equal(t, string(expected), g.RunesString())
+		}
+	}
 }
 
 func TestGapTable_Reallocate_NoGap(t *testing.T) {
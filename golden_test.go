@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Golden tests
+//
+// Each testdata/golden/<name>.keys is a script of keys, which is replayed on
+// <name>.txt (or a new file if it doesn't exist) in a 24x8 MemoryScreen. The buffer,
+// the screen and the cursor are then compared with <name>.golden.
+// Run `go test -run TestGolden -update` to rewrite the golden files.
+//
+// A line of a script is the bytes of a single read from the terminal, written as
+// the inside of a Go string literal (e.g. `abc\r` or `\x1b[A`), followed by a pause,
+// so that a lone `\x1b` is Escape. Empty lines and lines starting with # are skipped.
+
+var update = flag.Bool("update", false, "update the golden files")
+
+const (
+	goldenWidth  = 24
+	goldenHeight = 8
+)
+
+func TestGolden(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "golden", "*.keys"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, scripts)
+
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), ".keys")
+		t.Run(name, func(t *testing.T) {
+			testGolden(t, strings.TrimSuffix(script, ".keys"))
+		})
+	}
+}
+
+func testGolden(t *testing.T, base string) {
+	reads, err := readKeyScript(base + ".keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The editor opens the file in a temporary directory, so that the status bar
+	// shows the same name everywhere and saving doesn't touch testdata.
+	name := filepath.Base(base) + ".txt"
+	dir := t.TempDir()
+	if text, err := ioutil.ReadFile(base + ".txt"); err == nil {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), text, 0644))
+	}
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	s := newMemoryScreen(goldenWidth, goldenHeight)
+	e := newEditor(name, false, s)
+	var d keyDecoder
+	for _, b := range reads {
+		for _, ev := range append(d.feed(b), d.flush()...) {
+			e.interpretEvent(ev)
+		}
+	}
+
+	got := snapshot(e, s)
+	golden := filepath.Join(wd, base+".golden")
+
+	if *update {
+		assert.NoError(t, ioutil.WriteFile(golden, []byte(got), 0644))
+		return
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	assert.Equal(t, string(want), got)
+}
+
+// readKeyScript returns the reads in the script.
+func readKeyScript(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reads [][]byte
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		b, err := strconv.Unquote(`"` + line + `"`)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		reads = append(reads, []byte(b))
+	}
+
+	return reads, scanner.Err()
+}
+
+// snapshot prints the buffer, the screen without trailing spaces, and the cursor.
+func snapshot(e *Editor, s *MemoryScreen) string {
+	var buf bytes.Buffer
+
+	var text []rune
+	for i := 0; i < e.n; i++ {
+		text = append(text, e.rows[i].chars.Runes()...)
+	}
+
+	buf.WriteString("-- buffer --\n")
+	buf.WriteString(string(text))
+	if len(text) > 0 && text[len(text)-1] != '\n' {
+		buf.WriteString("\n\\ No newline at end of buffer\n")
+	}
+
+	buf.WriteString("-- screen --\n")
+	_, height := s.Size()
+	for row := 0; row < height; row++ {
+		buf.WriteString(strings.TrimRight(s.RowText(row), " "))
+		buf.WriteString("\n")
+	}
+
+	row, col := s.Cursor()
+	fmt.Fprintf(&buf, "-- cursor --\n%d %d\n", row, col)
+
+	return buf.String()
+}
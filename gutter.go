@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Line numbers
+//
+// The gutter on the left shows the line numbers, as wide as the number of the
+// last row. In the relative mode, the other rows show how far they are from the
+// cursor, which is handy to count the rows to move or kill. Alt+N switches the
+// modes, and -numbers sets the one to start with.
+
+type lineNumbering int
+
+const (
+	numbersOff lineNumbering = iota
+	numbersAbsolute
+	numbersRelative
+)
+
+var numberingNames = []string{"off", "absolute", "relative"}
+
+func (n lineNumbering) String() string {
+	return numberingNames[n]
+}
+
+func parseNumbering(s string) (lineNumbering, error) {
+	for i, name := range numberingNames {
+		if s == name {
+			return lineNumbering(i), nil
+		}
+	}
+	return numbersOff, fmt.Errorf("unknown line numbering: %q", s)
+}
+
+// gutterWidth returns the width of the line numbers and the space after them.
+func (e *Editor) gutterWidth() int {
+	if e.numbering == numbersOff {
+		return 0
+	}
+	return len(strconv.Itoa(e.n)) + 1
+}
+
+// textWidth returns the number of columns for the text, right of the gutter.
+func (e *Editor) textWidth() int {
+	width := e.screenWidth() - e.gutterWidth()
+	if width < 1 {
+		return 1
+	}
+	return width
+}
+
+// drawGutter draws the line numbers of the rows on the screen.
+func (e *Editor) drawGutter() {
+	if e.numbering == numbersOff {
+		return
+	}
+
+	cursorRow := e.crow + e.scroolrow
+	line := 0
+	for row := e.scroolrow; line < e.textHeight(); row++ {
+		label := ""
+		switch {
+		case row >= e.n:
+		case e.numbering == numbersRelative && row != cursorRow:
+			label = strconv.Itoa(abs(row - cursorRow))
+		default:
+			label = strconv.Itoa(row + 1)
+		}
+
+		label = fmt.Sprintf("%*s ", e.gutterWidth()-1, label)
+		drawRunes(e.screen, line, 0, []rune(label), nil, BgDefault)
+		for i := 1; i < e.rowHeight(row) && line+i < e.textHeight(); i++ {
+			drawRunes(e.screen, line+i, 0, []rune(fmt.Sprintf("%*s", e.gutterWidth(), "")), nil, BgDefault)
+		}
+		line += e.rowHeight(row)
+	}
+}
+
+func (e *Editor) setNumbering(n lineNumbering) {
+	e.numbering = n
+	e.scroolcol = 0
+	e.redrawAllRows()
+	e.setColPos(e.ccol)
+}
+
+// toggleNumbering switches the line numbers between off, absolute and relative.
+func (e *Editor) toggleNumbering() {
+	e.setNumbering((e.numbering + 1) % lineNumbering(len(numberingNames)))
+	e.setMessage("Line numbers: " + e.numbering.String())
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
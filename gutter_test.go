@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseNumbering(t *testing.T) {
+	n, err := parseNumbering("relative")
+	assert.NoError(t, err)
+	assert.Equal(t, numbersRelative, n)
+
+	_, err = parseNumbering("on")
+	assert.Error(t, err)
+}
+
+func TestGutter_Click(t *testing.T) {
+	e := makeEditor("abc\ndef\n")
+	s := attachScreen(e, 10, 5)
+	e.setNumbering(numbersAbsolute)
+	assert.Equal(t, "1 abc     ", s.RowText(0))
+
+	// A click on the gutter moves to the start of the row.
+	e.mouse(mouseEvent{action: mousePress, row: 1, col: 0})
+	assert.Equal(t, []int{1, 0}, []int{e.crow, e.ccol})
+	e.mouse(mouseEvent{action: mousePress, row: 0, col: 4})
+	assert.Equal(t, []int{0, 2}, []int{e.crow, e.ccol})
+
+	row, col := s.Cursor()
+	assert.Equal(t, []int{0, 4}, []int{row, col})
+}
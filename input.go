@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Key decoder
+//
+// Special keys arrive as escape sequences: CSI (ESC [ params final, e.g. ESC[3~ or
+// ESC[1;5C) and SS3 (ESC O final, e.g. ESC OH). A key pressed with Alt is sent as
+// ESC followed by the key. A sequence may be split across reads, so the decoder
+// keeps an incomplete one until the next read. If nothing follows within
+// escapeTimeout, the pending bytes are flushed; a lone ESC is the Escape key.
+//
+// In bracketed paste mode, the terminal wraps pasted text in pasteStart and
+// pasteEnd. The text in between is not decoded as keys, but delivered at once
+// as a Paste event.
+//
+// Mouse events are reported in the SGR format, ESC [ < button ; col ; row, ending
+// with M when pressed (or dragged) and m when released.
+
+const escapeTimeout = 50 * time.Millisecond
+
+const (
+	pasteStart = "\033[200~"
+	pasteEnd   = "\033[201~"
+)
+
+const mousePrefix = "\033[<"
+
+// keyEvent is a key, text pasted at once if key is Paste, or a mouse event if key is Mouse.
+type keyEvent struct {
+	key   rune
+	text  []rune
+	mouse mouseEvent
+}
+
+type mouseAction int
+
+const (
+	mousePress mouseAction = iota + 1
+	mouseDrag
+	mouseRelease
+	wheelUp
+	wheelDown
+)
+
+const (
+	mouseLeft = iota
+	mouseMiddle
+	mouseRight
+)
+
+type mouseEvent struct {
+	action   mouseAction
+	button   int
+	row, col int // on the screen, 0-origin
+}
+
+// The keys for the final byte of CSI and SS3 sequences.
+var finalKeys = map[byte]rune{
+	'A': ArrowUp,
+	'B': ArrowDown,
+	'C': ArrowRight,
+	'D': ArrowLeft,
+	'H': Home,
+	'F': End,
+	'P': F1,
+	'Q': F1 + 1,
+	'R': F1 + 2,
+	'S': F1 + 3,
+	'Z': Shift + Tab,
+}
+
+// The keys for the first parameter of CSI sequences ending with '~'.
+var tildeKeys = map[int]rune{
+	1:  Home,
+	2:  Insert,
+	3:  Delete,
+	4:  End,
+	5:  PageUp,
+	6:  PageDown,
+	7:  Home,
+	8:  End,
+	11: F1,
+	12: F1 + 1,
+	13: F1 + 2,
+	14: F1 + 3,
+	15: F1 + 4,
+	17: F1 + 5,
+	18: F1 + 6,
+	19: F1 + 7,
+	20: F1 + 8,
+	21: F1 + 9,
+	23: F1 + 10,
+	24: F1 + 11,
+}
+
+type keyDecoder struct {
+	buf []byte // bytes not decoded yet
+
+	pasting bool
+	pasted  []byte
+}
+
+// feed decodes the keys in b, keeping an incomplete sequence at the end for the next call.
+func (d *keyDecoder) feed(b []byte) []keyEvent {
+	d.buf = append(d.buf, b...)
+
+	var events []keyEvent
+	for len(d.buf) > 0 {
+		if d.pasting {
+			if !d.readPaste() {
+				break
+			}
+			events = append(events, keyEvent{key: Paste, text: pastedText(d.pasted)})
+			d.pasted = nil
+			continue
+		}
+
+		if bytes.HasPrefix(d.buf, []byte(pasteStart)) {
+			d.pasting = true
+			d.buf = d.buf[len(pasteStart):]
+			continue
+		}
+
+		if bytes.HasPrefix(d.buf, []byte(mousePrefix)) {
+			ev, n := decodeMouse(d.buf)
+			if n == 0 {
+				break
+			}
+
+			if ev.key != DummyKey {
+				events = append(events, ev)
+			}
+			d.buf = d.buf[n:]
+			continue
+		}
+
+		r, n := decodeKey(d.buf)
+		if n == 0 {
+			break
+		}
+
+		if r != DummyKey {
+			events = append(events, keyEvent{key: r})
+		}
+		d.buf = d.buf[n:]
+	}
+
+	return events
+}
+
+// readPaste moves the pasted bytes in buf to pasted, and returns true at pasteEnd.
+func (d *keyDecoder) readPaste() bool {
+	if i := bytes.Index(d.buf, []byte(pasteEnd)); i >= 0 {
+		d.pasted = append(d.pasted, d.buf[:i]...)
+		d.buf = d.buf[i+len(pasteEnd):]
+		d.pasting = false
+		return true
+	}
+
+	// Keep the bytes which may be the beginning of pasteEnd.
+	n := len(d.buf) - (len(pasteEnd) - 1)
+	if n > 0 {
+		d.pasted = append(d.pasted, d.buf[:n]...)
+		d.buf = d.buf[n:]
+	}
+	return false
+}
+
+// pending reports whether an incomplete sequence is waiting for the rest.
+// The rest of a paste is always waited for.
+func (d *keyDecoder) pending() bool {
+	return len(d.buf) > 0 && !d.pasting
+}
+
+// flush returns the keys for the incomplete sequence when nothing more is coming.
+func (d *keyDecoder) flush() []keyEvent {
+	if d.pasting {
+		return nil
+	}
+
+	b := d.buf
+	d.buf = nil
+
+	switch {
+	case len(b) == 0:
+		return nil
+	case len(b) == 1 && b[0] == Escape:
+		return []keyEvent{{key: Escape}}
+	case len(b) == 2 && b[0] == Escape:
+		// e.g. Alt+'[', or Alt+ESC
+		r, _ := decodeRune(b[1:])
+		return []keyEvent{{key: Alt + r}}
+	case b[0] == Escape:
+		// A broken sequence.
+		return nil
+	}
+
+	// A broken UTF-8 sequence is kept as raw bytes.
+	var events []keyEvent
+	for _, r := range decodeBytes(b) {
+		events = append(events, keyEvent{key: r})
+	}
+	return events
+}
+
+// pastedText decodes pasted bytes, with newlines as '\n' whatever the terminal sent.
+func pastedText(b []byte) []rune {
+	b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	b = bytes.ReplaceAll(b, []byte("\r"), []byte("\n"))
+	return decodeBytes(b)
+}
+
+// decodeKey decodes the key at the head of b, and returns its size.
+// The size is 0 if b is an incomplete sequence. Unknown sequences are DummyKey.
+func decodeKey(b []byte) (rune, int) {
+	if len(b) == 0 {
+		return DummyKey, 0
+	}
+
+	if b[0] != Escape {
+		if !utf8.FullRune(b) {
+			return DummyKey, 0
+		}
+		return decodeRune(b)
+	}
+
+	if len(b) == 1 {
+		return DummyKey, 0
+	}
+
+	switch b[1] {
+	case '[':
+		return decodeCSI(b)
+	case 'O':
+		return decodeSS3(b)
+	}
+
+	// Alt+key is sent as ESC followed by the key.
+	r, n := decodeKey(b[1:])
+	if n == 0 {
+		return DummyKey, 0
+	}
+	if r == DummyKey {
+		return DummyKey, n + 1
+	}
+	return Alt + r, n + 1
+}
+
+// decodeCSI decodes ESC [ params final.
+func decodeCSI(b []byte) (rune, int) {
+	i := 2
+	for i < len(b) && b[i] >= 0x20 && b[i] <= 0x3F {
+		i++
+	}
+	if i == len(b) {
+		return DummyKey, 0
+	}
+
+	final := b[i]
+	if final < 0x40 || final > 0x7E {
+		// Not a CSI sequence after all.
+		return DummyKey, i
+	}
+
+	params := parseParams(string(b[2:i]))
+
+	var key rune
+	var ok bool
+	if final == '~' {
+		key, ok = tildeKeys[param(params, 0, 0)]
+	} else {
+		key, ok = finalKeys[final]
+	}
+	if !ok {
+		return DummyKey, i + 1
+	}
+
+	return key + modifiers(param(params, 1, 1)), i + 1
+}
+
+// decodeMouse decodes an SGR mouse report, and returns its size, which is 0 if it is incomplete.
+func decodeMouse(b []byte) (keyEvent, int) {
+	i := len(mousePrefix)
+	for i < len(b) && (b[i] >= '0' && b[i] <= '9' || b[i] == ';') {
+		i++
+	}
+	if i == len(b) {
+		return keyEvent{}, 0
+	}
+
+	params := parseParams(string(b[len(mousePrefix):i]))
+	if (b[i] != 'M' && b[i] != 'm') || len(params) != 3 || params[1] < 1 || params[2] < 1 {
+		return keyEvent{key: DummyKey}, i + 1
+	}
+
+	// The button has 4 (Shift), 8 (Meta) and 16 (Ctrl) added, which are ignored,
+	// 32 while dragging, and 64 for the wheel.
+	cb := params[0]
+	if cb&64 != 0 && cb&2 != 0 {
+		// The horizontal wheel (66 and 67) isn't used.
+		return keyEvent{key: DummyKey}, i + 1
+	}
+
+	m := mouseEvent{button: cb & 3, row: params[2] - 1, col: params[1] - 1}
+	switch {
+	case cb&64 != 0 && cb&1 == 0:
+		m.action = wheelUp
+	case cb&64 != 0:
+		m.action = wheelDown
+	case b[i] == 'm':
+		m.action = mouseRelease
+	case cb&32 != 0:
+		m.action = mouseDrag
+	default:
+		m.action = mousePress
+	}
+
+	return keyEvent{key: Mouse, mouse: m}, i + 1
+}
+
+// decodeSS3 decodes ESC O final.
+func decodeSS3(b []byte) (rune, int) {
+	if len(b) < 3 {
+		return DummyKey, 0
+	}
+
+	key, ok := finalKeys[b[2]]
+	if !ok {
+		return DummyKey, 3
+	}
+	return key, 3
+}
+
+func parseParams(s string) []int {
+	if s == "" {
+		return nil
+	}
+
+	var params []int
+	for _, p := range strings.Split(s, ";") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			n = -1
+		}
+		params = append(params, n)
+	}
+	return params
+}
+
+// param returns params[i], or def if it is missing.
+func param(params []int, i int, def int) int {
+	if i >= len(params) || params[i] < 0 {
+		return def
+	}
+	return params[i]
+}
+
+// modifiers converts the modifier parameter (1 + Shift 1 | Alt 2 | Ctrl 4 | Meta 8) to ours.
+func modifiers(m int) rune {
+	if m < 1 {
+		return 0
+	}
+
+	var mods rune
+	m -= 1
+	if m&1 != 0 {
+		mods += Shift
+	}
+	if m&(2|8) != 0 {
+		mods += Alt
+	}
+	if m&4 != 0 {
+		mods += Ctrl
+	}
+	return mods
+}
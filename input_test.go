@@ -0,0 +1,141 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDecodeKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		key  rune
+		size int
+	}{
+		{"a", 'a', 1},
+		{"あい", 'あ', 3},
+		{"\x01", ControlA, 1},
+		{"\x1b[A", ArrowUp, 3},
+		{"\x1b[Dx", ArrowLeft, 3},
+		{"\x1bOH", Home, 3},
+		{"\x1b[F", End, 3},
+		{"\x1b[3~", Delete, 4},
+		{"\x1b[5~", PageUp, 4},
+		{"\x1b[6;5~", Ctrl + PageDown, 6},
+		{"\x1b[1;5C", Ctrl + ArrowRight, 6},
+		{"\x1b[1;2A", Shift + ArrowUp, 6},
+		{"\x1b[1;3D", Alt + ArrowLeft, 6},
+		{"\x1b[1;6H", Ctrl + Shift + Home, 6},
+		{"\x1bOP", F1, 3},
+		{"\x1b[15~", F1 + 4, 5},
+		{"\x1b[24~", F1 + 11, 5},
+		{"\x1b[Z", Shift + Tab, 3},
+		{"\x1bw", Alt + 'w', 2},
+		{"\x1bあ", Alt + 'あ', 4},
+		{"\x1b\x1b[A", Alt + ArrowUp, 4},
+
+		// Unknown sequences are consumed as a whole.
+		{"\x1b[99~abc", DummyKey, 5},
+		{"\x1b[1;2X", DummyKey, 6},
+		{"\x1bOz", DummyKey, 3},
+
+		// Incomplete sequences need more bytes.
+		{"", DummyKey, 0},
+		{"\x1b", DummyKey, 0},
+		{"\x1b[", DummyKey, 0},
+		{"\x1b[1;5", DummyKey, 0},
+		{"\x1bO", DummyKey, 0},
+		{"\xe3\x81", DummyKey, 0},
+		{"\x1b\xe3", DummyKey, 0},
+	}
+
+	for _, tt := range tests {
+		key, size := decodeKey([]byte(tt.in))
+		assert.Equal(t, tt.key, key, "%q", tt.in)
+		assert.Equal(t, tt.size, size, "%q", tt.in)
+	}
+}
+
+func eventKeys(events []keyEvent) []rune {
+	var keys []rune
+	for _, ev := range events {
+		keys = append(keys, ev.key)
+	}
+	return keys
+}
+
+func TestKeyDecoder_Split(t *testing.T) {
+	var d keyDecoder
+
+	assert.Equal(t, []rune{'a'}, eventKeys(d.feed([]byte("a\x1b[1"))))
+	assert.True(t, d.pending())
+	assert.Equal(t, []rune{Ctrl + ArrowRight, 'b'}, eventKeys(d.feed([]byte(";5Cb\xe3\x81"))))
+	assert.Equal(t, []rune{'あ'}, eventKeys(d.feed([]byte("\x82"))))
+	assert.False(t, d.pending())
+
+	// The rest of the read isn't dropped after an unknown sequence.
+	assert.Equal(t, []rune{'x', 'y'}, eventKeys(d.feed([]byte("\x1b[99~xy"))))
+}
+
+func TestKeyDecoder_Flush(t *testing.T) {
+	var d keyDecoder
+
+	d.feed([]byte("\x1b"))
+	assert.Equal(t, []rune{Escape}, eventKeys(d.flush()))
+	assert.False(t, d.pending())
+
+	d.feed([]byte("\x1b["))
+	assert.Equal(t, []rune{Alt + '['}, eventKeys(d.flush()))
+
+	d.feed([]byte("\x1b\x1b"))
+	assert.Equal(t, []rune{Alt + Escape}, eventKeys(d.flush()))
+
+	d.feed([]byte("\x1b[1;"))
+	assert.Nil(t, eventKeys(d.flush()))
+
+	d.feed([]byte("a\xe3\x81"))
+	assert.Equal(t, []rune{rawByteMin + 0xe3 - 0x80, rawByteMin + 0x81 - 0x80}, eventKeys(d.flush()))
+
+	assert.Nil(t, eventKeys(d.flush()))
+}
+
+func TestKeyDecoder_Paste(t *testing.T) {
+	var d keyDecoder
+
+	events := d.feed([]byte("a\x1b[200~if x {\r\tb\x1b[A\r}\x1b[201~c"))
+	assert.Equal(t, []rune{'a', Paste, 'c'}, eventKeys(events))
+	assert.Equal(t, "if x {\n\tb\x1b[A\n}", string(events[1].text))
+
+	// The markers and the text may be split anywhere.
+	assert.Nil(t, d.feed([]byte("\x1b[20")))
+	assert.True(t, d.pending())
+	assert.Nil(t, d.feed([]byte("0~ab\r\n\xe3\x81")))
+	assert.False(t, d.pending())
+	assert.Nil(t, d.flush())
+	assert.Nil(t, d.feed([]byte("\x82\x1b[2")))
+	events = d.feed([]byte("01~\x1b[B"))
+	assert.Equal(t, []rune{Paste, ArrowDown}, eventKeys(events))
+	assert.Equal(t, "ab\nあ", string(events[0].text))
+}
+
+func TestKeyDecoder_Mouse(t *testing.T) {
+	var d keyDecoder
+
+	events := d.feed([]byte("\x1b[<0;5;3M\x1b[<32;7;4M\x1b[<0;7;4ma\x1b[<64;1;1M\x1b[<65;1;1M"))
+	assert.Equal(t, []rune{Mouse, Mouse, Mouse, 'a', Mouse, Mouse}, eventKeys(events))
+	assert.Equal(t, mouseEvent{action: mousePress, button: mouseLeft, row: 2, col: 4}, events[0].mouse)
+	assert.Equal(t, mouseEvent{action: mouseDrag, button: mouseLeft, row: 3, col: 6}, events[1].mouse)
+	assert.Equal(t, mouseEvent{action: mouseRelease, button: mouseLeft, row: 3, col: 6}, events[2].mouse)
+	assert.Equal(t, wheelUp, events[4].mouse.action)
+	assert.Equal(t, wheelDown, events[5].mouse.action)
+
+	// The horizontal wheel is ignored.
+	events = d.feed([]byte("\x1b[<66;1;1M\x1b[<67;1;1Mc"))
+	assert.Equal(t, []rune{'c'}, eventKeys(events))
+
+	// A report may be split, and broken ones are dropped.
+	assert.Nil(t, d.feed([]byte("\x1b[<2;10")))
+	assert.True(t, d.pending())
+	events = d.feed([]byte(";20M\x1b[<1;2Mb"))
+	assert.Equal(t, []rune{Mouse, 'b'}, eventKeys(events))
+	assert.Equal(t, mouseEvent{action: mousePress, button: mouseRight, row: 19, col: 9}, events[0].mouse)
+}
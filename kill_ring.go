@@ -0,0 +1,139 @@
+package main
+
+// Kill ring
+//
+// Killed (and copied) text is kept in a ring of the last killRingSize entries.
+// Consecutive kills are appended into one entry. Ctrl+Y yanks the newest entry,
+// and Alt+Y right after a yank replaces the yanked text with the older one.
+// The newest entry is shared with the system clipboard. See clipboard.go.
+
+const killRingSize = 16
+
+type KillRing struct {
+	entries [][]rune // the newest is the last
+	index   int      // the entry yanked last
+}
+
+func (k *KillRing) push(text []rune) {
+	k.entries = append(k.entries, append([]rune{}, text...))
+	if len(k.entries) > killRingSize {
+		k.entries = k.entries[1:]
+	}
+	k.index = len(k.entries) - 1
+}
+
+// appendLast adds text to the newest entry, before it if prepend is true.
+func (k *KillRing) appendLast(text []rune, prepend bool) {
+	if len(k.entries) == 0 {
+		k.push(text)
+		return
+	}
+
+	last := k.entries[len(k.entries)-1]
+	if prepend {
+		last = append(append([]rune{}, text...), last...)
+	} else {
+		last = append(last, text...)
+	}
+	k.entries[len(k.entries)-1] = last
+	k.index = len(k.entries) - 1
+}
+
+// newest returns the newest entry, or nil if the ring is empty.
+func (k *KillRing) newest() []rune {
+	if len(k.entries) == 0 {
+		return nil
+	}
+
+	k.index = len(k.entries) - 1
+	return k.entries[k.index]
+}
+
+// older returns the entry before the one yanked last, wrapping around.
+func (k *KillRing) older() []rune {
+	if len(k.entries) == 0 {
+		return nil
+	}
+
+	k.index = (k.index - 1 + len(k.entries)) % len(k.entries)
+	return k.entries[k.index]
+}
+
+type yankRange struct {
+	startRow, startCol int
+	endRow, endCol     int
+}
+
+func isKillKey(r rune) bool {
+	switch r {
+	case ControlK, ControlW, Alt + 'w', Alt + 'd', Alt + BackSpace, Alt + ControlH:
+		return true
+	}
+	return false
+}
+
+// isYankKey reports whether r inserts a kill ring entry, which Alt+Y can replace.
+func isYankKey(r rune) bool {
+	return r == ControlY || r == ControlV || r == Alt+'y'
+}
+
+// kill saves text to the kill ring, appending it to the last kill if the previous key killed.
+func (e *Editor) kill(text []rune, prepend bool) {
+	if isKillKey(e.lastKey) {
+		e.killRing.appendLast(text, prepend)
+	} else {
+		e.killRing.push(text)
+	}
+	e.copyToClipboard(e.killRing.newest())
+}
+
+// killLine kills the rest of the row, or the newline if the cursor is at the end.
+func (e *Editor) killLine() {
+	row := e.crow + e.scroolrow
+	r := e.rows[row]
+
+	if e.ccol < r.visibleLen() {
+		e.kill(e.deleteText(row, e.ccol, row, r.visibleLen()), false)
+	} else if row+1 < e.n {
+		e.kill(e.deleteText(row, e.ccol, row+1, 0), false)
+	} else {
+		return
+	}
+
+	e.redrawAllRows()
+	e.setColPos(e.ccol)
+}
+
+func (e *Editor) yank() {
+	// Text copied in another application becomes the newest entry.
+	if text := e.pasteFromClipboard(); len(text) > 0 && string(text) != string(e.killRing.newest()) {
+		e.killRing.push(text)
+	}
+	e.insertYank(e.killRing.newest())
+}
+
+func (e *Editor) yankPop() {
+	if e.yanked == nil || !isYankKey(e.lastKey) {
+		e.setMessage("Previous command was not a yank")
+		return
+	}
+
+	y := e.yanked
+	e.deleteText(y.startRow, y.startCol, y.endRow, y.endCol)
+	e.jumpTo(y.startRow, y.startCol)
+	e.insertYank(e.killRing.older())
+}
+
+func (e *Editor) insertYank(text []rune) {
+	if len(text) == 0 {
+		return
+	}
+
+	e.mark = nil
+	startRow, startCol := e.crow+e.scroolrow, e.ccol
+	endRow, endCol := e.insertText(startRow, startCol, text)
+	e.yanked = &yankRange{startRow, startCol, endRow, endCol}
+
+	e.redrawAllRows()
+	e.jumpTo(endRow, endCol)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestKillRing_Push(t *testing.T) {
+	k := &KillRing{}
+	assert.Nil(t, k.newest())
+	assert.Nil(t, k.older())
+
+	k.push([]rune("a"))
+	k.push([]rune("b"))
+	assert.Equal(t, "b", string(k.newest()))
+	assert.Equal(t, "a", string(k.older()))
+	assert.Equal(t, "b", string(k.older()))
+	assert.Equal(t, "b", string(k.newest()))
+}
+
+func TestKillRing_Size(t *testing.T) {
+	k := &KillRing{}
+	for i := 0; i < killRingSize+2; i++ {
+		k.push([]rune{rune('a' + i)})
+	}
+
+	assert.Equal(t, killRingSize, len(k.entries))
+	assert.Equal(t, string(rune('a'+killRingSize+1)), string(k.newest()))
+	assert.Equal(t, "c", string(k.entries[0]))
+}
+
+func TestKillRing_AppendLast(t *testing.T) {
+	k := &KillRing{}
+	k.appendLast([]rune("foo"), false)
+	k.appendLast([]rune("bar"), false)
+	k.appendLast([]rune("baz"), true)
+
+	assert.Equal(t, 1, len(k.entries))
+	assert.Equal(t, "bazfoobar", string(k.newest()))
+}
+
+func TestKill_Consecutive(t *testing.T) {
+	e := makeEditor("")
+
+	e.lastKey = 'x'
+	e.kill([]rune("foo\n"), false)
+	e.lastKey = ControlK
+	e.kill([]rune("bar\n"), false)
+	assert.Equal(t, "foo\nbar\n", string(e.killRing.newest()))
+
+	e.lastKey = ArrowDown
+	e.kill([]rune("baz"), false)
+	assert.Equal(t, "baz", string(e.killRing.newest()))
+	assert.Equal(t, 2, len(e.killRing.entries))
+}
+
+func TestYankPop(t *testing.T) {
+	for _, key := range []rune{ControlY, ControlV} {
+		e := makeEditor("\n")
+		attachScreen(e, 80, 12)
+		e.killRing.push([]rune("foo"))
+		e.killRing.push([]rune("bar"))
+
+		e.interpretKey(key)
+		assert.Equal(t, "bar\n", e.rows[0].chars.RunesString())
+		e.interpretKey(Alt + 'y')
+		assert.Equal(t, "foo\n", e.rows[0].chars.RunesString())
+		e.interpretKey(Alt + 'y')
+		assert.Equal(t, "bar\n", e.rows[0].chars.RunesString())
+	}
+}
@@ -3,9 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
-	"golang.org/x/sys/unix"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"syscall"
@@ -15,24 +15,52 @@ import (
 
 // Key Definitions
 const (
-	DummyKey   = -1
-	ControlA   = 1
-	ControlB   = 2
-	ControlC   = 3
-	ControlE   = 5
-	ControlF   = 6
-	ControlH   = 8
-	Tab        = 9
-	Enter      = 13
-	ControlN   = 14
-	ControlP   = 16
-	ControlS   = 19
-	ControlV   = 22
-	BackSpace  = 127
-	ArrowUp    = 1000
-	ArrowDown  = 1001
-	ArrowRight = 1002
-	ArrowLeft  = 1003
+	DummyKey         = -1
+	ControlSpace     = 0
+	ControlA         = 1
+	ControlB         = 2
+	ControlC         = 3
+	ControlE         = 5
+	ControlF         = 6
+	ControlG         = 7
+	ControlH         = 8
+	Tab              = 9
+	ControlK         = 11
+	ControlL         = 12
+	Enter            = 13
+	ControlN         = 14
+	ControlP         = 16
+	ControlR         = 18
+	ControlS         = 19
+	ControlT         = 20
+	ControlV         = 22
+	ControlW         = 23
+	ControlY         = 25
+	ControlZ         = 26
+	Escape           = 27
+	ControlBackslash = 28
+	BackSpace        = 127
+
+	// Special keys are beyond the range of Unicode, so they never clash with a character.
+	ArrowUp    = 0x110000
+	ArrowDown  = 0x110001
+	ArrowRight = 0x110002
+	ArrowLeft  = 0x110003
+	Home       = 0x110004
+	End        = 0x110005
+	PageUp     = 0x110006
+	PageDown   = 0x110007
+	Insert     = 0x110008
+	Delete     = 0x110009
+	F1         = 0x110010 // F1 + n - 1 is Fn, up to F12
+	Paste      = 0x110020 // see keyEvent
+	Mouse      = 0x110021 // see keyEvent
+
+	// Modifiers are added to a key, e.g. Alt+'w' or Ctrl+ArrowRight.
+	// Ctrl with a letter is sent as a control character (e.g. ControlA) instead.
+	Alt   = 1 << 21
+	Ctrl  = 1 << 22
+	Shift = 1 << 23
 )
 
 // Color Definition
@@ -40,21 +68,23 @@ type color int
 
 const (
 	DummyColor color = 37
+	Reverse          = 7
+	FgBlack          = 30
 	FgGreen          = 32
 	FgCyan           = 36
+	FgDefault        = 39
 	BgBlack          = 40
+	BgYellow         = 43
 	BgCyan           = 46
+	BgDefault        = 49
 )
 
 const (
-	helpMessage = "HELP: Ctrl+S = Save / Ctrl+C = Quit"
+	helpMessage = "HELP: Ctrl+S = Save / Ctrl+C = Quit / Ctrl+R = Search / Ctrl+T = Replace / Ctrl+Z = Undo / Alt+Z = Redo"
 )
 
-type messageType int
-
-const (
-	resetMessage messageType = iota + 1
-)
+// messageDuration is how long a message stays in the message bar.
+const messageDuration = 2 * time.Second
 
 type Keyword string
 
@@ -116,23 +146,36 @@ var keywordColor = map[Keyword]color{
 
 type Editor struct {
 	filePath  string
-	keyChan   chan rune
-	timeChan  chan messageType
+	prompt    *Prompt
+	search    *search
+	mark      *mark
+	drag      *mark // where the left button was pressed, while it is held
+	killRing  *KillRing
+	clipboard Clipboard
+	yanked    *yankRange
+	lastKey   rune
+	keyChan   chan keyEvent
+	sigChan   chan os.Signal
+	funcChan  chan func()
+	msgTimer  *time.Timer
 	crow      int
 	ccol      int
 	scroolrow int
+	scroolcol int // the column drawn at the left edge of the screen
+	wrap      bool
+	numbering lineNumbering
+	gutter    int  // the width of the gutter drawn last
+	quit      bool // set to stop the loop after the current key
+	encoding  string
+	eol       string
 	rows      []*Row
 	terminal  *Terminal
+	screen    Screen
+	history   *History
 	n         int  // numberOfRows
 	debug     bool // for debug
 }
 
-type Terminal struct {
-	termios *unix.Termios
-	width   int
-	height  int
-}
-
 type Row struct {
 	chars *GapTable
 	// render
@@ -160,79 +203,79 @@ func (e *Editor) debugRowRunes() {
 	}
 }
 
-// Terminal
-func makeRaw(fd int) *unix.Termios {
-	termios, err := unix.IoctlGetTermios(fd, unix.TIOCGETA)
-	if err != nil {
-		panic(err)
-	}
-
-	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
-	termios.Oflag &^= unix.OPOST
-	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
-	termios.Cflag &^= unix.CSIZE | unix.PARENB
-	termios.Cflag |= unix.CS8
-	termios.Cc[unix.VMIN] = 1
-	termios.Cc[unix.VTIME] = 0
-	if err := unix.IoctlSetTermios(fd, unix.TIOCSETA, termios); err != nil {
-		panic(err)
-	}
+func (e *Editor) initScreen() {
+	e.writeHelpMenu(helpMessage)
+	e.writeStatusBar()
+	e.refreshAllRows()
+	e.setRowCol(0, 0)
+}
 
-	return termios
+// screenWidth returns the number of columns of the screen.
+func (e *Editor) screenWidth() int {
+	width, _ := e.screen.Size()
+	return width
 }
 
-func (e *Editor) restoreTerminal(fd int) {
-	if err := unix.IoctlSetTermios(fd, unix.TIOCSETA, e.terminal.termios); err != nil {
-		panic(err)
+// textHeight returns the number of rows for the text, above the status and message bars.
+func (e *Editor) textHeight() int {
+	_, height := e.screen.Size()
+	if height < 3 {
+		return 1
 	}
+	return height - 2
 }
 
-func getWindowSize(fd int) (int, int) {
-	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
-	if err != nil {
-		panic(err)
+// resize redraws everything after the screen is resized, keeping the cursor in the screen.
+func (e *Editor) resize() {
+	row := e.crow + e.scroolrow
+
+	if row >= e.scroolrow+e.textHeight() {
+		e.scroolrow = row - e.textHeight() + 1
+	}
+	if e.scroolrow > 0 && e.scroolrow > e.n-e.textHeight() {
+		e.scroolrow = e.n - e.textHeight()
+		if e.scroolrow < 0 {
+			e.scroolrow = 0
+		}
 	}
-	return int(ws.Col), int(ws.Row)
-}
 
-func (e *Editor) initTerminal() {
-	e.flush()
-	e.writeHelpMenu(helpMessage)
+	e.refreshAllRows()
 	e.writeStatusBar()
-	e.moveCursor(e.crow, e.ccol)
+	if e.prompt != nil {
+		e.writePrompt()
+	} else {
+		e.writeHelpMenu(helpMessage)
+	}
+
+	e.crow = row - e.scroolrow
+	e.scroolcol = 0
+	e.setColPos(e.ccol)
 }
 
 func (e *Editor) writeHelpMenu(message string) {
-	prevRow, prevCol := e.crow, e.ccol
-
-	for i, ch := range message {
-		e.moveCursor(e.terminal.height+1, i)
-		e.write([]byte(string(ch)))
-	}
+	message = truncateString(message, e.screenWidth())
 
-	for i := len(message); i < e.terminal.width; i++ {
-		e.moveCursor(e.terminal.height+1, i)
-		e.write([]byte{' '})
-	}
-
-	e.moveCursor(prevRow, prevCol)
+	clearRow(e.screen, e.textHeight()+1, BgDefault)
+	drawRunes(e.screen, e.textHeight()+1, 0, []rune(message), nil, BgDefault)
 }
 
-func (e *Editor) writeStatusBar() {
-	e.setBgColor(BgCyan)
-	defer e.setBgColor(BgBlack)
+// setMessage shows message in the message bar for messageDuration.
+func (e *Editor) setMessage(message string) {
+	e.writeHelpMenu(message)
 
-	// Write file name
-	for i, ch := range e.filePath {
-		e.moveCursor(e.terminal.height, i)
-		e.write([]byte(string(ch)))
+	if e.msgTimer != nil {
+		e.msgTimer.Stop()
 	}
+	e.msgTimer = time.NewTimer(messageDuration)
+}
 
-	// Write Spacer
-	for i := len(e.filePath); i < e.terminal.width; i++ {
-		e.moveCursor(e.terminal.height, i)
-		e.write([]byte{' '})
+// messageTimeout returns the channel which fires when the message should be cleared,
+// or nil (which never fires) if there is no message.
+func (e *Editor) messageTimeout() <-chan time.Time {
+	if e.msgTimer == nil {
+		return nil
 	}
+	return e.msgTimer.C
 }
 
 // Views
@@ -240,42 +283,35 @@ func (e *Editor) write(b []byte) {
 	syscall.Write(0, b)
 }
 
-func (e *Editor) writeWithColor(b []byte, colors []color) {
-	var newBuf []byte
-
-	for i, c := range colors {
-		s := fmt.Sprintf("\033[%dm", c)
-		newBuf = append(newBuf, []byte(s)...)
-		newBuf = append(newBuf, b[i])
-	}
-
-	syscall.Write(0, newBuf)
+func (e *Editor) flush() {
+	e.screen.Flush()
 }
 
-func (e *Editor) highlight(b []byte) []color {
-	colors := make([]color, len(b))
+func (e *Editor) highlight(runes []rune) []color {
+	colors := make([]color, len(runes))
 	for i := range colors {
 		colors[i] = DummyColor
 	}
 
-	// ASCII-only
-	ascii := string(b)
+	text := string(runes)
 
 	// Keywords
 	for key := range keywordColor {
-		index := strings.Index(ascii, string(key))
+		index := strings.Index(text, string(key))
 		if index != -1 {
+			// Convert the byte offset into a rune offset.
+			start := utf8.RuneCountInString(text[:index])
 			for i := 0; i < len(string(key)); i += 1 {
-				colors[index+i] = keywordColor[key]
+				colors[start+i] = keywordColor[key]
 			}
 		}
 	}
 
 	// String Literal
 	isStringLit := false
-	for i, b := range ascii {
-		if b == '"' || isStringLit {
-			if b == '"' {
+	for i, r := range runes {
+		if r == '"' || isStringLit {
+			if r == '"' {
 				isStringLit = !isStringLit
 			}
 			colors[i] = FgGreen
@@ -285,57 +321,123 @@ func (e *Editor) highlight(b []byte) []color {
 	return colors
 }
 
-func (e *Editor) writeRow(r *Row) {
-	var buf []byte
+// reverseColors draws runes in [from, to) with reverse video.
+// If colors is nil, the other runes are drawn with the default color.
+func reverseColors(colors []color, n int, from, to int) []color {
+	return paintColors(colors, n, from, to, Reverse)
+}
+
+// paintColors draws runes in [from, to) with c.
+// If colors is nil, the other runes are drawn with the default color.
+func paintColors(colors []color, n int, from, to int, c color) []color {
+	if colors == nil {
+		colors = make([]color, n)
+		for i := range colors {
+			colors[i] = FgDefault
+		}
+	}
 
-	for _, r := range r.chars.Runes() {
-		buf = append(buf, []byte(string(r))...)
+	for i := from; i < to && i < n; i++ {
+		colors[i] = c
 	}
 
-	e.moveCursor(e.crow, 0)
-	e.flushRow()
+	return colors
+}
+
+// rowColors returns the colors of the runes in the row at index row.
+func (e *Editor) rowColors(r *Row, row int) []color {
+	runes := r.chars.Runes()
+
+	var colors []color
 
 	// If the extension of fileName is .go, write with highlights.
 	if filepath.Ext(e.filePath) == ".go" {
-		colors := e.highlight(buf)
-		e.writeWithColor(buf, colors)
-	} else {
-		e.write(buf)
+		colors = e.highlight(runes)
 	}
-}
 
-func (e *Editor) flush() {
-	e.write([]byte("\033[2J"))
+	colors = e.highlightMatch(row, colors, len(runes))
+	return e.highlightRegion(row, colors, r.visibleLen())
 }
 
-func (e *Editor) flushRow() {
-	e.write([]byte("\033[2K"))
-}
+func (e *Editor) writeRow(r *Row) {
+	if e.wrap {
+		// The row may take more or fewer lines now, which moves the rows below.
+		prevRowPos := e.crow
+		e.drawWrappedRows()
+		e.crow = prevRowPos
+		return
+	}
+
+	runes := r.chars.Runes()
+	colors := e.rowColors(r, e.crow+e.scroolrow)
 
-func (e *Editor) setBgColor(color color) {
-	s := fmt.Sprintf("\033[%dm", color)
-	e.write([]byte(s))
+	// The part scrolled out to the left is drawn under the gutter and the indicator.
+	clearRow(e.screen, e.crow, BgDefault)
+	drawRunes(e.screen, e.crow, e.gutterWidth()-e.scroolcol, runes, colors, BgDefault)
+	for col := 0; col < e.gutterWidth(); col++ {
+		e.screen.SetCell(e.crow, col, blankCell(BgDefault))
+	}
+
+	// Show that the row goes on beyond the edges.
+	if e.scroolcol > 0 && r.visibleLen() > 0 {
+		e.screen.SetCell(e.crow, e.gutterWidth(), cell{text: "<", color: Reverse, bg: BgDefault})
+	}
+	if runesWidth(r.visibleRunes())-e.scroolcol > e.textWidth() {
+		e.screen.SetCell(e.crow, e.screenWidth()-1, cell{text: ">", color: Reverse, bg: BgDefault})
+	}
 }
 
 func (e *Editor) moveCursor(row, col int) {
-	s := fmt.Sprintf("\033[%d;%dH", row+1, col+1) // 0-origin to 1-origin
-	e.write([]byte(s))
+	e.screen.MoveCursor(row, col)
 }
 
 func (e *Editor) updateRowRunes(row *Row) {
-	if e.crow < e.terminal.height {
+	if e.crow < e.textHeight() {
 		e.debugPrint("DEBUG: row's view updated at", e.crow + e.scroolrow, "for", row.chars.Runes())
 		e.writeRow(row)
 	}
 }
 
 func (e *Editor) refreshAllRows() {
-	for i := 0; i < e.terminal.height; i += 1 {
+	if e.wrap {
+		e.drawWrappedRows()
+		return
+	}
+
+	for i := 0; i < e.textHeight(); i += 1 {
 		e.crow = i
 		e.writeRow(e.rows[e.scroolrow+i])
 	}
 }
 
+// redrawAllRows refreshes all rows, keeping the cursor where it is.
+func (e *Editor) redrawAllRows() {
+	prevRowPos := e.crow
+	e.refreshAllRows()
+	e.crow = prevRowPos
+	e.showCursor()
+}
+
+// showCursor moves the cursor on the screen to the cursor in the buffer, and
+// updates the line numbers for it.
+func (e *Editor) showCursor() {
+	if e.gutter != e.gutterWidth() {
+		// The text moves as the number of digits changes.
+		e.gutter = e.gutterWidth()
+		prevRowPos := e.crow
+		e.refreshAllRows()
+		e.crow = prevRowPos
+	}
+	e.drawGutter()
+
+	if e.wrap {
+		row, col := e.wrappedCursor()
+		e.moveCursor(row, col+e.gutterWidth())
+		return
+	}
+	e.moveCursor(e.crow, e.gutterWidth()+e.cursorColumn()-e.scroolcol)
+}
+
 func (e *Editor) setRowPos(row int) {
 	if row >= e.n {
 		row = e.n - 1
@@ -350,16 +452,16 @@ func (e *Editor) setRowPos(row int) {
 		row = 0
 	}
 
-	if row >= e.terminal.height {
+	if row >= e.textHeight() {
 		if row+e.scroolrow <= e.n {
 			e.scroolrow += 1
 		}
-		row = e.terminal.height - 1
+		row = e.textHeight() - 1
 		e.refreshAllRows()
 	}
 
 	e.crow = row
-	e.moveCursor(row, e.ccol)
+	e.showCursor()
 }
 
 func (e *Editor) setColPos(col int) {
@@ -371,12 +473,66 @@ func (e *Editor) setColPos(col int) {
 		col = e.currentRow().visibleLen()
 	}
 
-	if col >= e.terminal.width {
-		col = e.terminal.width - 1
+	e.ccol = col
+	if e.wrap {
+		e.scrollToCursorLine()
+	} else {
+		e.scrollColumn()
 	}
+	e.showCursor()
+}
 
-	e.ccol = col
-	e.moveCursor(e.crow, e.ccol)
+// scrollColumn scrolls horizontally if the cursor is out of the screen or under an indicator,
+// putting it in the middle.
+func (e *Editor) scrollColumn() {
+	x := e.cursorColumn()
+	width := e.textWidth()
+	rowWidth := runesWidth(e.currentRow().visibleRunes())
+
+	left := x > e.scroolcol || x == 0
+	right := x < e.scroolcol+width-1 || (x == e.scroolcol+width-1 && rowWidth <= e.scroolcol+width)
+	if left && right {
+		return
+	}
+
+	e.scroolcol = x - width/2
+	if e.scroolcol < 0 {
+		e.scroolcol = 0
+	}
+
+	prevRowPos := e.crow
+	e.refreshAllRows()
+	e.crow = prevRowPos
+}
+
+// jumpTo moves the cursor to (row, col) of the buffer, scrolling if the row is out of the screen.
+func (e *Editor) jumpTo(row, col int) {
+	if row >= e.n {
+		row = e.n - 1
+	}
+
+	if row < 0 {
+		row = 0
+	}
+
+	if row < e.scroolrow || row >= e.scroolrow+e.shownRows() {
+		e.centerRow(row)
+	}
+
+	e.crow = row - e.scroolrow
+	e.setColPos(col)
+}
+
+// setRowKeepColumn moves the cursor to row, keeping it at the same screen column.
+func (e *Editor) setRowKeepColumn(row int) {
+	x := e.cursorColumn()
+	e.setRowPos(row)
+	e.setColPos(e.currentRow().indexAt(x))
+}
+
+// cursorColumn returns the column of the cursor in the row, which is drawn at cursorColumn()-scroolcol.
+func (e *Editor) cursorColumn() int {
+	return e.currentRow().columnAt(e.ccol)
 }
 
 func (e *Editor) setRowCol(row int, col int) {
@@ -408,22 +564,46 @@ func (r *Row) insertAt(colPos int, newRune rune) {
 func (r *Row) len() int        { return r.chars.Len() }
 func (r *Row) visibleLen() int { return r.chars.VisibleLen() }
 
+// visibleRunes returns the runes of the row without the trailing newline.
+func (r *Row) visibleRunes() []rune { return r.chars.Runes()[:r.visibleLen()] }
+
+// columnAt returns the screen column of the rune at col. See width.go.
+func (r *Row) columnAt(col int) int { return columnOf(r.visibleRunes(), col) }
+
+// indexAt returns the rune index drawn at the screen column x.
+func (r *Row) indexAt(x int) int { return indexOf(r.visibleRunes(), x) }
+
+func (r *Row) nextCluster(col int) int { return clusterEnd(r.visibleRunes(), col) }
+func (r *Row) prevCluster(col int) int { return clusterStart(r.visibleRunes(), col) }
+
 func (e *Editor) currentRow() *Row {
 	return e.rows[e.crow + e.scroolrow]
 }
 
-func (e *Editor) deleteRune(row *Row, col int) {
-	row.deleteAt(col)
-	e.updateRowRunes(row)
-	e.setRowCol(e.crow, e.ccol - 1)
+func (e *Editor) deleteRune(row int, col int) {
+	r := e.rows[row]
+	if col >= r.len() {
+		return
+	}
+
+	e.recordEdit(&editOp{kind: opDeleteRune, row: row, col: col, runes: []rune{r.chars.At(col)}})
+	r.deleteAt(col)
+	e.updateRowRunes(r)
 }
 
-func (e *Editor) insertRune(row *Row, col int, newRune rune) {
-	row.insertAt(col, newRune)
-	e.updateRowRunes(row)
+func (e *Editor) insertRune(row int, col int, newRune rune) {
+	r := e.rows[row]
+	if col > r.len() {
+		col = r.len()
+	}
+
+	e.recordEdit(&editOp{kind: opInsertRune, row: row, col: col, runes: []rune{newRune}})
+	r.insertAt(col, newRune)
+	e.updateRowRunes(r)
 }
 
 func (e *Editor) deleteRow(row int) {
+	e.recordEdit(&editOp{kind: opDeleteRow, row: row, runes: e.rows[row].chars.Runes()})
 	e.rows = append(e.rows[:row], e.rows[row+1:]...)
 	e.n -= 1
 
@@ -433,6 +613,13 @@ func (e *Editor) deleteRow(row int) {
 }
 
 func (e *Editor) replaceRune(row int, newRune []rune) {
+	e.recordEdit(&editOp{
+		kind:  opReplaceRow,
+		row:   row,
+		runes: append([]rune{}, newRune...),
+		prev:  e.rows[row].chars.Runes(),
+	})
+
 	gt := NewGapTable(128)
 
 	for _, r := range newRune {
@@ -452,6 +639,8 @@ func (e *Editor) replaceRune(row int, newRune []rune) {
 }
 
 func (e *Editor) insertRow(row int, runes []rune) {
+	e.recordEdit(&editOp{kind: opInsertRow, row: row, runes: append([]rune{}, runes...)})
+
 	gt := NewGapTable(128)
 
 	for _, r := range runes {
@@ -477,6 +666,9 @@ func (e *Editor) reallocBufferIfNeeded() {
 		newCap := cap(e.rows) * 2
 		newRows := make([]*Row, newCap)
 		copy(newRows, e.rows)
+		for i := len(e.rows); i < newCap; i++ {
+			newRows[i] = &Row{chars: NewGapTable(128)}
+		}
 		e.rows = newRows
 		e.debugPrint("DEBUG: realloc occurred")
 	}
@@ -503,19 +695,51 @@ func (e *Editor) backspace() {
 			e.setRowCol(e.crow - 1, prevRow.len() - 1)
 		}
 	} else {
-		e.deleteRune(row, e.ccol - 1)
+		// Delete the whole grapheme cluster before the cursor.
+		start := row.prevCluster(e.ccol)
+		for col := e.ccol; col > start; col-- {
+			e.deleteRune(e.crow+e.scroolrow, col-1)
+		}
+		e.setRowCol(e.crow, start)
 	}
 
 	e.debugRowRunes()
 }
 
+// deleteForward deletes the grapheme cluster after the cursor, or joins the next row.
+// The cursor stays where it is, so that undo puts it back before the deleted text.
+func (e *Editor) deleteForward() {
+	rowPos := e.crow + e.scroolrow
+	row := e.currentRow()
+
+	if e.ccol < row.visibleLen() {
+		end := row.nextCluster(e.ccol)
+		for col := end; col > e.ccol; col-- {
+			e.deleteRune(rowPos, col-1)
+		}
+		e.setColPos(e.ccol)
+		return
+	}
+
+	if rowPos+1 >= e.n {
+		return
+	}
+
+	newRunes := append([]rune{}, row.chars.Runes()[:row.len()-1]...)
+	newRunes = append(newRunes, e.rows[rowPos+1].chars.Runes()...)
+	col := e.ccol
+	e.replaceRune(rowPos, newRunes)
+	e.deleteRow(rowPos + 1)
+	e.setRowCol(e.crow, col)
+}
+
 func (e *Editor) back() {
 	if e.ccol == 0 {
 		if e.crow > 0 {
 			e.setRowCol(e.crow-1, e.rows[e.crow+e.scroolrow-1].visibleLen())
 		}
 	} else {
-		e.setRowCol(e.crow, e.ccol-1)
+		e.setRowCol(e.crow, e.currentRow().prevCluster(e.ccol))
 	}
 }
 
@@ -525,7 +749,7 @@ func (e *Editor) next() {
 			e.setRowCol(e.crow+1, 0)
 		}
 	} else {
-		e.setRowCol(e.crow, e.ccol+1)
+		e.setRowCol(e.crow, e.currentRow().nextCluster(e.ccol))
 	}
 }
 
@@ -548,23 +772,94 @@ func (e *Editor) newLine() {
 	e.debugRowRunes()
 }
 
-func existsFile(filename string) bool {
-	_, err := os.Stat(filename)
-	return err == nil
+// insertText inserts text at (row, col), splitting rows at newlines like newLine.
+// It returns the position just after the inserted text.
+func (e *Editor) insertText(row, col int, text []rune) (int, int) {
+	lines := splitLines(text)
+	runes := e.rows[row].chars.Runes()
+
+	head := append([]rune{}, runes[:col]...)
+	tail := append([]rune{}, runes[col:]...)
+
+	if len(lines) == 1 {
+		newRunes := append(head, text...)
+		e.replaceRune(row, append(newRunes, tail...))
+		return row, col + len(text)
+	}
+
+	e.replaceRune(row, append(append(head, lines[0]...), '\n'))
+	for i := 1; i < len(lines)-1; i++ {
+		e.insertRow(row+i, append(append([]rune{}, lines[i]...), '\n'))
+	}
+
+	last := lines[len(lines)-1]
+	e.insertRow(row+len(lines)-1, append(append([]rune{}, last...), tail...))
+
+	return row + len(lines) - 1, len(last)
 }
 
-func saveFile(filePath string, rows []*Row) {
-	sb := strings.Builder{}
+// paste inserts text pasted in the terminal as it is, which is undone at once.
+func (e *Editor) paste(text []rune) {
+	if e.prompt != nil {
+		e.promptPaste(text)
+		return
+	}
+
+	row, col := e.crow+e.scroolrow, e.ccol
+	e.history.checkpoint(false, row, col)
+	endRow, endCol := e.insertText(row, col, text)
+	e.history.commit(endRow, endCol)
 
-	for _, r := range rows {
-		if r.len() >= 1 {
-			for _, ch := range r.chars.Runes() {
-				sb.WriteRune(ch)
-			}
+	e.redrawAllRows()
+	e.jumpTo(endRow, endCol)
+	e.lastKey = Paste
+}
+
+// deleteText deletes the text in [(startRow, startCol), (endRow, endCol)),
+// joining rows like backspace. It returns the deleted text.
+func (e *Editor) deleteText(startRow, startCol, endRow, endCol int) []rune {
+	text := e.textBetween(startRow, startCol, endRow, endCol)
+
+	head := e.rows[startRow].chars.Runes()[:startCol]
+	tail := e.rows[endRow].chars.Runes()[endCol:]
+	e.replaceRune(startRow, append(append([]rune{}, head...), tail...))
+
+	for row := endRow; row > startRow; row-- {
+		e.deleteRow(row)
+	}
+
+	return text
+}
+
+// textBetween returns the text in [(startRow, startCol), (endRow, endCol)).
+func (e *Editor) textBetween(startRow, startCol, endRow, endCol int) []rune {
+	if startRow == endRow {
+		return append([]rune{}, e.rows[startRow].chars.Runes()[startCol:endCol]...)
+	}
+
+	text := append([]rune{}, e.rows[startRow].chars.Runes()[startCol:]...)
+	for row := startRow + 1; row < endRow; row++ {
+		text = append(text, e.rows[row].chars.Runes()...)
+	}
+	return append(text, e.rows[endRow].chars.Runes()[:endCol]...)
+}
+
+// splitLines splits text at newlines, dropping them.
+func splitLines(text []rune) [][]rune {
+	lines := [][]rune{{}}
+	for _, r := range text {
+		if r == '\n' {
+			lines = append(lines, []rune{})
+			continue
 		}
+		lines[len(lines)-1] = append(lines[len(lines)-1], r)
 	}
+	return lines
+}
 
-	_ = ioutil.WriteFile(filePath, []byte(sb.String()), 0644)
+func existsFile(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
 }
 
 func loadFile(filePath string) *Editor {
@@ -573,23 +868,38 @@ func loadFile(filePath string) *Editor {
 		ccol:      0,
 		scroolrow: 0,
 		filePath:  filePath,
-		keyChan:   make(chan rune),
-		timeChan:  make(chan messageType),
+		keyChan:   make(chan keyEvent),
+		sigChan:   make(chan os.Signal, 1),
+		funcChan:  make(chan func()),
+		history:   &History{},
+		killRing:  &KillRing{},
+		rows:      makeRows(),
 		n:         1,
 	}
 
-	rows := makeRows()
-
 	bytes, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		panic(err)
 	}
+	e.encoding = detectEncoding(bytes)
+	e.eol = detectLineEnding(bytes)
+	if e.encoding == "UTF-8 BOM" {
+		// The BOM isn't part of the text. It is written back on saving.
+		bytes = bytes[len(utf8BOM):]
+	}
 
 	gt := NewGapTable(128)
 
-	for _, b := range bytes {
+	// Invalid UTF-8 bytes are kept as raw byte runes. See encoding.go.
+	runes := decodeBytes(bytes)
+	for i, r := range runes {
+		// CRLF rows are edited as LF ones, and saved back as CRLF.
+		if e.eol == "CRLF" && r == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+			continue
+		}
+
 		// Treat TAB as 4 spaces.
-		if b == Tab {
+		if r == Tab {
 			gt.AppendRune(rune(0x20))
 			gt.AppendRune(rune(0x20))
 			gt.AppendRune(rune(0x20))
@@ -597,18 +907,17 @@ func loadFile(filePath string) *Editor {
 			continue
 		}
 
-		// ASCII-only
-		gt.AppendRune(rune(b))
+		gt.AppendRune(r)
 
-		if b == '\n' {
-			rows[e.n-1] = &Row{chars: gt}
+		if r == '\n' {
+			e.rows[e.n-1] = &Row{chars: gt}
 			e.n += 1
+			e.reallocBufferIfNeeded()
 			gt = NewGapTable(128)
 		}
 	}
 
-	rows[e.n-1] = &Row{chars: gt}
-	e.rows = rows
+	e.rows[e.n-1] = &Row{chars: gt}
 
 	return e
 }
@@ -617,126 +926,216 @@ func (e *Editor) exit() {
 	e.restoreTerminal(0)
 }
 
-func (e *Editor) parseKey(b []byte) (rune, int) {
-	// Try parsing escape sequence
-	if len(b) == 3 {
-		if b[0] == byte(27) && b[1] == '[' {
-			switch b[2] {
-			case 'A':
-				return ArrowUp, 3
-			case 'B':
-				return ArrowDown, 3
-			case 'C':
-				return ArrowRight, 3
-			case 'D':
-				return ArrowLeft, 3
-			default:
-				return DummyKey, 0
+func (e *Editor) readKeys() {
+	buf := make([]byte, 64)
+	var d keyDecoder
+
+	for {
+		if d.pending() && !waitInput(0, escapeTimeout) {
+			// Nothing followed, e.g. ESC was pressed on its own.
+			for _, ev := range d.flush() {
+				e.keyChan <- ev
 			}
+			continue
 		}
-	}
 
-	// parse bytes as UTF-8.
-	return utf8.DecodeRune(b)
-}
+		n, err := syscall.Read(0, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n == 0 {
+			// The terminal is gone.
+			close(e.keyChan)
+			return
+		}
 
-func (e *Editor) readKeys() {
-	buf := make([]byte, 64)
+		for _, ev := range d.feed(buf[:n]) {
+			e.keyChan <- ev
+		}
+	}
+}
 
+// loop runs the editor until it quits or the keys are closed.
+// All the events are handled here, one at a time, so the editor state is only
+// touched by this goroutine. Other goroutines send their events through channels.
+func (e *Editor) loop() {
 	for {
-		if n, err := syscall.Read(0, buf); err == nil {
-			b := buf[:n]
-			for {
-				r, n := e.parseKey(b)
+		select {
+		case ev, ok := <-e.keyChan:
+			if !ok || !e.interpretEvent(ev) {
+				return
+			}
 
-				if n == 0 {
-					break
-				}
+		case <-e.sigChan:
+			width, height := getWindowSize(0)
+			e.screen.Resize(width, height)
+			e.resize()
 
-				e.keyChan <- r
-				b = b[n:]
-			}
+		case <-e.messageTimeout():
+			e.msgTimer = nil
+			e.writeHelpMenu(helpMessage)
+
+		case f := <-e.funcChan:
+			f()
 		}
+
+		e.flush()
 	}
 }
 
-func (e *Editor) interpretKey() {
-	for {
-		r := <-e.keyChan
+// interpretEvent handles a key, a paste or a mouse event, and returns false if the editor should quit.
+func (e *Editor) interpretEvent(ev keyEvent) bool {
+	switch ev.key {
+	case Paste:
+		e.paste(ev.text)
+	case Mouse:
+		e.history.checkpoint(false, e.crow+e.scroolrow, e.ccol)
+		e.mouse(ev.mouse)
+		e.lastKey = Mouse
+	default:
+		if !e.interpretKey(ev.key) {
+			return false
+		}
+	}
 
-		switch r {
-		case ControlA:
-			e.setRowCol(e.crow, 0)
+	e.writeStatusBar()
+	return true
+}
 
-		case ControlB, ArrowLeft:
-			e.back()
+// interpretKey handles a key, and returns false if the editor should quit.
+func (e *Editor) interpretKey(r rune) bool {
+	// Consecutive typing is undone at once.
+	e.history.checkpoint(isTypingKey(r), e.crow+e.scroolrow, e.ccol)
 
-		case ControlC:
-			e.exit()
-			return
+	if e.prompt != nil {
+		e.promptKey(r)
+		e.lastKey = r
+		return !e.quit
+	}
 
-		case ControlE:
-			e.setRowCol(e.crow, e.numberOfRunesInRow())
+	switch r {
+	case ControlA, Home:
+		e.setRowCol(e.crow, 0)
 
-		case ControlF, ArrowRight:
-			e.next()
+	case ControlB, ArrowLeft:
+		e.back()
 
-		case ControlH, BackSpace:
-			e.backspace()
+	case ControlC:
+		e.requestQuit()
 
-		case ControlN, ArrowDown:
-			e.setRowCol(e.crow+1, e.ccol)
+	case ControlE, End:
+		e.setRowCol(e.crow, e.numberOfRunesInRow())
 
-		case Tab:
-			for i := 0; i < 4; i += 1 {
-				e.insertRune(e.currentRow(), e.ccol, rune(' '))
-			}
-			e.setColPos(e.ccol + 4)
+	case ControlF, ArrowRight:
+		e.next()
 
-		case Enter:
-			e.newLine()
+	case Alt + 'f', Ctrl + ArrowRight:
+		e.forwardWord()
 
-		case ControlS:
-			saveFile(e.filePath, e.rows)
-			e.writeHelpMenu("Saved!")
-			e.timeChan <- resetMessage
+	case Alt + 'b', Ctrl + ArrowLeft:
+		e.backwardWord()
 
-		case ControlP, ArrowUp:
-			e.setRowCol(e.crow-1, e.ccol)
+	case Alt + 'd':
+		e.killWord()
 
-		// for debug
-		case ControlV:
-			e.debugDetailPrint(e)
+	case Alt + BackSpace, Alt + ControlH:
+		e.backwardKillWord()
 
-		default:
-			e.insertRune(e.currentRow(), e.ccol, r)
-			e.setColPos(e.ccol + 1)
+	case ControlH, BackSpace:
+		e.backspace()
+
+	case Delete:
+		e.deleteForward()
+
+	case ControlN, ArrowDown:
+		e.moveLine(1)
+
+	case Tab:
+		for i := 0; i < 4; i += 1 {
+			e.insertRune(e.crow+e.scroolrow, e.ccol, rune(' '))
 		}
-	}
-}
+		e.setColPos(e.ccol + 4)
 
-func (e *Editor) pollTimerEvent() {
-	for {
-		switch <-e.timeChan {
-		case resetMessage:
-			t := time.NewTimer(2 * time.Second)
-			<-t.C
-			e.writeHelpMenu(helpMessage)
+	case Enter:
+		e.newLine()
+
+	case ControlS:
+		e.save()
+
+	case ControlP, ArrowUp:
+		e.moveLine(-1)
+
+	case PageUp:
+		e.movePage(-1)
+
+	case PageDown:
+		e.movePage(1)
+
+	case Alt + '<', Ctrl + Home:
+		e.moveToStart()
+
+	case Alt + '>', Ctrl + End:
+		e.moveToEnd()
+
+	case ControlL:
+		e.startGoToLine()
+
+	case ControlR:
+		e.startSearch()
+
+	case ControlT:
+		e.startReplace()
+
+	case ControlZ:
+		e.undo()
+
+	case Alt + 'z':
+		e.redo()
+
+	case Alt + 'l':
+		e.toggleWrap()
+
+	case Alt + 'n':
+		e.toggleNumbering()
+
+	case ControlSpace:
+		e.toggleMark()
+
+	case ControlG:
+		e.clearMark()
+
+	case Alt + 'w':
+		e.copyRegion()
+
+	case ControlW:
+		e.cutRegion()
+
+	case ControlK:
+		e.killLine()
+
+	case ControlV, ControlY:
+		e.yank()
+
+	case Alt + 'y':
+		e.yankPop()
+
+	// for debug
+	case ControlBackslash:
+		e.debugDetailPrint(e)
+
+	default:
+		if isTypingKey(r) {
+			e.insertRune(e.crow+e.scroolrow, e.ccol, r)
+			e.setColPos(e.ccol + 1)
 		}
 	}
-}
-
-func newTerminal(fd int) *Terminal {
-	termios := makeRaw(fd)
-	width, height := getWindowSize(fd)
 
-	terminal := &Terminal{
-		termios: termios,
-		width:   width,
-		height:  height - 2, // for status, message bar
+	if e.mark != nil {
+		e.redrawAllRows()
 	}
 
-	return terminal
+	e.lastKey = r
+	return !e.quit
 }
 
 func makeRows() []*Row {
@@ -749,50 +1148,62 @@ func makeRows() []*Row {
 	return rows
 }
 
-func newEditor(filePath string, debug bool) *Editor {
-	terminal := newTerminal(0)
+// newEditor opens filePath, and draws it on screen.
+func newEditor(filePath string, debug bool, screen Screen) *Editor {
+	var e *Editor
 
 	if existsFile(filePath) {
-		e := loadFile(filePath)
-		e.debug = debug
-		e.terminal = terminal
-		return e
+		e = loadFile(filePath)
+	} else {
+		e = &Editor{
+			crow:      0,
+			ccol:      0,
+			scroolrow: 0,
+			rows:      makeRows(),
+			filePath:  filePath,
+			keyChan:   make(chan keyEvent),
+			sigChan:   make(chan os.Signal, 1),
+			funcChan:  make(chan func()),
+			history:   &History{},
+			killRing:  &KillRing{},
+			n:         1,
+			encoding:  "UTF-8",
+			eol:       "LF",
+		}
 	}
 
-	rows := makeRows()
-	return &Editor{
-		crow:      0,
-		ccol:      0,
-		scroolrow: 0,
-		rows:      rows,
-		filePath:  filePath,
-		keyChan:   make(chan rune),
-		timeChan:  make(chan messageType),
-		terminal:  terminal,
-		n:         1,
-		debug:     debug,
-	}
+	e.debug = debug
+	e.screen = screen
+	e.initScreen()
+	return e
 }
 
-func run(filePath string, debug bool) {
-	e := newEditor(filePath, debug)
-	e.initTerminal()
-	e.refreshAllRows()
-	e.setRowCol(0, 0)
+func run(filePath string, debug bool, numbering lineNumbering) {
+	terminal := newTerminal(0)
+	width, height := getWindowSize(0)
+
+	e := newEditor(filePath, debug, newTerminalScreen(0, width, height))
+	e.terminal = terminal
+	e.setNumbering(numbering)
+	e.clipboard = newClipboard(e.write)
+	signal.Notify(e.sigChan, syscall.SIGWINCH)
+	e.flush()
 
 	go e.readKeys()
-	go e.pollTimerEvent()
-	e.interpretKey()
+	e.loop()
+	e.exit()
 }
 
 func main() {
+	numbers := flag.String("numbers", "off", "line numbers: off, absolute or relative")
 	flag.Parse()
 
-	if flag.NArg() < 1 || flag.NArg() > 3 {
-		fmt.Println("Usage: mille <filename> [--debug]")
+	numbering, err := parseNumbering(*numbers)
+	if err != nil || flag.NArg() < 1 || flag.NArg() > 3 {
+		fmt.Println("Usage: mille [-numbers off|absolute|relative] <filename> [--debug]")
 		return
 	}
 
 	debug := flag.NArg() == 2 && flag.Arg(1) == "--debug"
-	run(flag.Arg(0), debug)
+	run(flag.Arg(0), debug, numbering)
 }
@@ -2,6 +2,8 @@ package main
 
 import (
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 )
 
@@ -15,6 +17,37 @@ func makeEmptyRow() *Row {
 	}
 }
 
+// makeEditor makes an editor holding text, without a terminal.
+func makeEditor(text string) *Editor {
+	e := &Editor{
+		rows:     makeRows(),
+		n:        1,
+		history:  &History{},
+		killRing: &KillRing{},
+	}
+
+	gt := NewGapTable(128)
+	for _, ch := range text {
+		gt.AppendRune(ch)
+		if ch == '\n' {
+			e.rows[e.n-1] = &Row{chars: gt}
+			e.n += 1
+			gt = NewGapTable(128)
+		}
+	}
+	e.rows[e.n-1] = &Row{chars: gt}
+
+	return e
+}
+
+// attachScreen draws e on a MemoryScreen of width x height, including the status and message bars.
+func attachScreen(e *Editor, width, height int) *MemoryScreen {
+	s := newMemoryScreen(width, height)
+	e.screen = s
+	e.initScreen()
+	return s
+}
+
 func makeAlphaRow() *Row {
 	gt := NewGapTable(128)
 	gt.AppendRune(97)
@@ -65,3 +98,94 @@ func TestInsertAt_ASCII(t *testing.T) {
 	assert.Equal(t, 5, row.len())
 	assert.Equal(t, r(101), row.chars.At(4))
 }
+
+func TestSplitLines(t *testing.T) {
+	assert.Equal(t, [][]rune{[]rune("abc")}, splitLines([]rune("abc")))
+	assert.Equal(t, [][]rune{[]rune("a"), []rune("b"), {}}, splitLines([]rune("a\nb\n")))
+}
+
+func TestTextBetween(t *testing.T) {
+	e := makeEditor("hello\nworld\nfoo\n")
+	assert.Equal(t, "ell", string(e.textBetween(0, 1, 0, 4)))
+	assert.Equal(t, "llo\nworld\nf", string(e.textBetween(0, 2, 2, 1)))
+	assert.Equal(t, "\n", string(e.textBetween(0, 5, 1, 0)))
+}
+
+func TestResize(t *testing.T) {
+	e := makeEditor("0\n1\n2\n3\n4\n5\n6\n7\n8\n9\nabcdefghij\n")
+	s := attachScreen(e, 20, 12)
+	e.jumpTo(10, 8)
+
+	// The cursor stays in the smaller screen.
+	s.Resize(6, 6)
+	e.resize()
+	assert.Equal(t, 6, e.screenWidth())
+	assert.Equal(t, 4, e.textHeight())
+	assert.Equal(t, 10, e.crow+e.scroolrow)
+	assert.Equal(t, 3, e.crow)
+	assert.Equal(t, 8, e.ccol)
+	assert.Equal(t, 5, e.scroolcol)
+	assert.Equal(t, "<ghij ", s.RowText(3))
+	assert.Equal(t, BgCyan, int(s.Cell(4, 0).bg))
+
+	row, col := s.Cursor()
+	assert.Equal(t, 3, row)
+	assert.Equal(t, 3, col)
+
+	// The rows above come back into a larger screen.
+	s.Resize(20, 22)
+	e.resize()
+	assert.Equal(t, 0, e.scroolrow)
+	assert.Equal(t, 10, e.crow)
+	assert.Equal(t, 0, e.scroolcol)
+}
+
+func TestLoop(t *testing.T) {
+	e := makeEditor("")
+	s := attachScreen(e, 20, 5)
+	e.keyChan = make(chan keyEvent)
+	e.funcChan = make(chan func())
+
+	done := make(chan struct{})
+	go func() {
+		e.loop()
+		close(done)
+	}()
+
+	for _, r := range "ab" {
+		e.keyChan <- keyEvent{key: r}
+	}
+	// A message doesn't block the next one.
+	e.keyChan <- keyEvent{key: Alt + 'w'}
+	e.keyChan <- keyEvent{key: Alt + 'w'}
+
+	var text string
+	e.funcChan <- func() { text = e.rows[0].chars.RunesString() }
+	close(e.keyChan)
+	<-done
+
+	assert.Equal(t, "ab", text)
+	assert.Equal(t, "ab", s.RowText(0)[:2])
+}
+
+func TestNewEditor_Screen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("hello\nworld\n"), 0644))
+
+	s := newMemoryScreen(16, 5)
+	e := newEditor(path, false, s)
+
+	for _, r := range []rune{ControlN, ControlE, '!', Enter, 'x'} {
+		e.interpretKey(r)
+	}
+
+	assert.Equal(t, "hello           ", s.RowText(0))
+	assert.Equal(t, "world!          ", s.RowText(1))
+	assert.Equal(t, "x               ", s.RowText(2))
+	assert.Equal(t, path[:2]+" Ln 1/2, Col 1", s.RowText(3))
+	assert.Equal(t, BgCyan, int(s.Cell(3, 0).bg))
+
+	row, col := s.Cursor()
+	assert.Equal(t, 2, row)
+	assert.Equal(t, 1, col)
+}
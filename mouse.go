@@ -0,0 +1,113 @@
+package main
+
+// Mouse
+//
+// A click moves the cursor to the clicked character, and dragging with the left
+// button selects the region from where it was pressed, like setting the mark there.
+// The wheel scrolls the text by wheelRows, moving the cursor only if it would go
+// out of the screen.
+
+const wheelRows = 3
+
+func (e *Editor) mouse(m mouseEvent) {
+	if e.prompt != nil {
+		return
+	}
+
+	switch m.action {
+	case wheelUp:
+		e.scroll(-wheelRows)
+	case wheelDown:
+		e.scroll(wheelRows)
+	case mousePress:
+		if m.button != mouseLeft || m.row >= e.textHeight() {
+			return
+		}
+		e.clearMark()
+		e.moveToScreen(m.row, m.col)
+		e.drag = &mark{row: e.crow + e.scroolrow, col: e.ccol}
+	case mouseDrag:
+		if e.drag == nil {
+			return
+		}
+		e.mark = e.drag
+		e.moveToScreen(m.row, m.col)
+		e.redrawAllRows()
+	case mouseRelease:
+		e.drag = nil
+	}
+}
+
+// moveToScreen moves the cursor to the character drawn at (row, col) of the screen,
+// or the nearest one in the text area.
+func (e *Editor) moveToScreen(row, col int) {
+	col -= e.gutterWidth()
+	if col < 0 {
+		col = 0
+	}
+	if row >= e.textHeight() {
+		row = e.textHeight() - 1
+	}
+
+	if e.wrap {
+		// Find the row drawn on the line.
+		bufRow := e.scroolrow
+		for bufRow+1 < e.n && row >= e.rowHeight(bufRow) {
+			row -= e.rowHeight(bufRow)
+			bufRow++
+		}
+
+		starts := e.wrapStarts(bufRow)
+		if row >= len(starts) {
+			row = len(starts) - 1
+		}
+		e.crow = bufRow - e.scroolrow
+		e.setColPos(indexInLine(e.currentRow().visibleRunes(), starts, row, col))
+		return
+	}
+
+	if row+e.scroolrow >= e.n {
+		row = e.n - 1 - e.scroolrow
+	}
+
+	e.crow = row
+	e.setColPos(e.currentRow().indexAt(col + e.scroolcol))
+}
+
+// scroll scrolls the text by n rows, keeping the cursor on the same row of the
+// buffer as long as it stays on the screen.
+func (e *Editor) scroll(n int) {
+	scroolrow := e.scroolrow + n
+	if scroolrow > e.lastScroolrow() {
+		scroolrow = e.lastScroolrow()
+	}
+	if scroolrow < 0 {
+		scroolrow = 0
+	}
+	if scroolrow == e.scroolrow {
+		return
+	}
+
+	prevRow := e.crow + e.scroolrow
+	e.scroolrow = scroolrow
+
+	row := prevRow
+	if row < e.scroolrow {
+		row = e.scroolrow
+	}
+	if row >= e.scroolrow+e.shownRows() {
+		row = e.scroolrow + e.shownRows() - 1
+	}
+
+	col := e.ccol
+	if e.wrap && row != prevRow {
+		// Stay on the first line of the row, which is on the screen.
+		if starts := e.wrapStarts(row); len(starts) > 1 && col >= starts[1] {
+			col = clusterStart(e.rows[row].visibleRunes(), starts[1])
+		}
+	}
+
+	e.refreshAllRows()
+	e.crow = row - e.scroolrow
+	e.setColPos(col)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Navigation
+//
+// PageUp and PageDown scroll the text by a screen, moving the cursor with it.
+// Go to line takes `line` or `line:col`, both counted from 1, and centers the line.
+
+// movePage moves the cursor and the text by pages screens, keeping the screen column.
+func (e *Editor) movePage(pages int) {
+	x := e.cursorColumn()
+	row := e.crow + e.scroolrow + pages*e.textHeight()
+
+	e.scroll(pages * e.textHeight())
+	e.jumpTo(row, 0)
+	e.setColPos(e.currentRow().indexAt(x))
+}
+
+func (e *Editor) moveToStart() {
+	e.jumpTo(0, 0)
+}
+
+func (e *Editor) moveToEnd() {
+	e.jumpTo(e.n-1, e.rows[e.n-1].visibleLen())
+}
+
+// centerRow scrolls so that row is in the middle of the screen, as far as the buffer allows.
+func (e *Editor) centerRow(row int) {
+	e.scroolrow = row - e.textHeight()/2
+	if e.scroolrow > e.lastScroolrow() {
+		e.scroolrow = e.lastScroolrow()
+	}
+	if e.scroolrow < 0 {
+		e.scroolrow = 0
+	}
+	e.refreshAllRows()
+}
+
+func (e *Editor) startGoToLine() {
+	e.startPrompt(&Prompt{
+		label: "Go to line: ",
+		onDone: func(input []rune, ok bool) {
+			if !ok || len(input) == 0 {
+				return
+			}
+
+			row, col, err := parseLineCol(string(input))
+			if err != nil {
+				e.setMessage(fmt.Sprintf("Invalid line: %s", string(input)))
+				return
+			}
+
+			if row >= e.n {
+				row = e.n - 1
+			}
+			e.centerRow(row)
+			e.jumpTo(row, col)
+		},
+	})
+}
+
+// parseLineCol parses `line` or `line:col` into 0-origin row and col.
+func parseLineCol(s string) (row, col int, err error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 2)
+
+	line, err := strconv.Atoi(parts[0])
+	if err != nil || line < 1 {
+		return 0, 0, fmt.Errorf("invalid line: %q", s)
+	}
+
+	if len(parts) == 1 {
+		return line - 1, 0, nil
+	}
+
+	c, err := strconv.Atoi(parts[1])
+	if err != nil || c < 1 {
+		return 0, 0, fmt.Errorf("invalid column: %q", s)
+	}
+	return line - 1, c - 1, nil
+}
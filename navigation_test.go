@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseLineCol(t *testing.T) {
+	tests := []struct {
+		in       string
+		row, col int
+		ok       bool
+	}{
+		{"1", 0, 0, true},
+		{"42", 41, 0, true},
+		{" 42:7 ", 41, 6, true},
+		{"0", 0, 0, false},
+		{"x", 0, 0, false},
+		{"3:", 0, 0, false},
+		{"3:0", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		row, col, err := parseLineCol(tt.in)
+		if !tt.ok {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.row, row, tt.in)
+		assert.Equal(t, tt.col, col, tt.in)
+	}
+}
+
+func TestMovePage(t *testing.T) {
+	e := makeEditor("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+	attachScreen(e, 20, 6) // 4 rows of text
+
+	e.movePage(1)
+	assert.Equal(t, 4, e.scroolrow)
+	assert.Equal(t, 4, e.crow+e.scroolrow)
+
+	e.movePage(1)
+	e.movePage(1)
+	assert.Equal(t, e.n-4, e.scroolrow)
+	assert.Equal(t, e.n-1, e.crow+e.scroolrow)
+
+	e.movePage(-1)
+	assert.Equal(t, e.n-8, e.scroolrow)
+	assert.Equal(t, e.n-5, e.crow+e.scroolrow)
+
+	e.moveToStart()
+	assert.Equal(t, 0, e.scroolrow)
+	assert.Equal(t, 0, e.crow)
+}
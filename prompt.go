@@ -0,0 +1,91 @@
+package main
+
+// Prompt reads a line of input in the message bar.
+// While a prompt is active, interpretKey sends every key to promptKey.
+type Prompt struct {
+	label string
+	input []rune
+
+	// onKey is called first for every key, and returns true if it handled the key.
+	onKey func(key rune) bool
+
+	// onChange is called whenever the input changes.
+	onChange func(input []rune)
+
+	// onDone is called when the input is submitted (ok is true) or canceled.
+	onDone func(input []rune, ok bool)
+}
+
+func (e *Editor) startPrompt(p *Prompt) {
+	e.prompt = p
+	e.writePrompt()
+}
+
+func (e *Editor) writePrompt() {
+	e.writeHelpMenu(e.prompt.label + string(e.prompt.input))
+}
+
+func (e *Editor) promptKey(r rune) {
+	p := e.prompt
+
+	if p.onKey != nil && p.onKey(r) {
+		e.afterPromptKey(p)
+		return
+	}
+
+	switch r {
+	case Enter:
+		e.closePrompt()
+		if p.onDone != nil {
+			p.onDone(p.input, true)
+		}
+
+	case Escape, ControlG, ControlC:
+		e.closePrompt()
+		if p.onDone != nil {
+			p.onDone(p.input, false)
+		}
+
+	case ControlH, BackSpace:
+		if len(p.input) > 0 {
+			p.input = p.input[:clusterStart(p.input, len(p.input))]
+			if p.onChange != nil {
+				p.onChange(p.input)
+			}
+		}
+
+	default:
+		if isTypingKey(r) {
+			p.input = append(p.input, r)
+			if p.onChange != nil {
+				p.onChange(p.input)
+			}
+		}
+	}
+
+	e.afterPromptKey(p)
+}
+
+// promptPaste adds the first line of pasted text to the input.
+func (e *Editor) promptPaste(text []rune) {
+	p := e.prompt
+	p.input = append(p.input, splitLines(text)[0]...)
+	if p.onChange != nil {
+		p.onChange(p.input)
+	}
+
+	e.afterPromptKey(p)
+}
+
+// closePrompt removes the prompt from the message bar.
+// onDone is called after this, so that it can start another prompt or leave a message.
+func (e *Editor) closePrompt() {
+	e.prompt = nil
+	e.writeHelpMenu(helpMessage)
+}
+
+func (e *Editor) afterPromptKey(p *Prompt) {
+	if e.prompt == p {
+		e.writePrompt()
+	}
+}
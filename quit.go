@@ -0,0 +1,39 @@
+package main
+
+// Quit
+//
+// Ctrl+C quits at once if the buffer is the same as when it was loaded or last
+// saved. Otherwise it asks first, and Ctrl+C again quits anyway.
+
+// modified reports whether the buffer has changed since it was loaded or saved.
+// Undoing back to the saved state counts as unchanged.
+func (e *Editor) modified() bool {
+	return e.history.modified()
+}
+
+// requestQuit quits, asking first if there are unsaved changes.
+func (e *Editor) requestQuit() {
+	if !e.modified() {
+		e.quit = true
+		return
+	}
+
+	e.startPrompt(&Prompt{
+		label: "Unsaved changes, quit anyway? (y)es / (n)o / (s)ave and quit",
+		onKey: func(key rune) bool {
+			switch key {
+			case 'y', ControlC:
+				e.closePrompt()
+				e.quit = true
+
+			case 's':
+				e.closePrompt()
+				e.quit = e.save() == nil
+
+			case 'n', Enter, Escape, ControlG:
+				e.closePrompt()
+			}
+			return true
+		},
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestModified(t *testing.T) {
+	e := makeEditor("abc\n")
+	attachScreen(e, 20, 5)
+	assert.False(t, e.modified())
+
+	e.interpretKey('x')
+	assert.True(t, e.modified())
+
+	// Undoing back to the saved state is unchanged.
+	e.interpretKey(ControlZ)
+	assert.False(t, e.modified())
+	e.interpretKey(Alt + 'z')
+	assert.True(t, e.modified())
+
+	e.history.markSaved(0, 1)
+	assert.False(t, e.modified())
+	e.interpretKey(ControlZ)
+	assert.True(t, e.modified())
+}
+
+func TestQuit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("abc\n"), 0644))
+
+	e := newEditor(path, false, newMemoryScreen(80, 5))
+	assert.False(t, e.interpretKey(ControlC))
+
+	// Unsaved changes are asked about.
+	e = newEditor(path, false, newMemoryScreen(80, 5))
+	e.interpretKey('x')
+	assert.True(t, e.interpretKey(ControlC))
+	assert.NotNil(t, e.prompt)
+	assert.True(t, e.interpretKey('n'))
+	assert.Nil(t, e.prompt)
+
+	assert.True(t, e.interpretKey(ControlC))
+	assert.False(t, e.interpretKey(ControlC))
+
+	// Save and quit.
+	e = newEditor(path, false, newMemoryScreen(80, 5))
+	e.interpretKey('x')
+	e.interpretKey(ControlC)
+	assert.False(t, e.interpretKey('s'))
+	text, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "xabc\n", string(text))
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// Query replace
+//
+// Matches of a regexp are replaced from the cursor to the end of the buffer, and
+// then from the top of the buffer back to the cursor, asking at each match. The
+// template may refer to submatches as $1 or ${name}. After a match is skipped,
+// the next one may overlap it. The whole replacement is undone at once.
+
+type replace struct {
+	re       *regexp.Regexp
+	template string
+
+	// re after any rune at the start of the text, made by matchAt.
+	after *regexp.Regexp
+
+	// The current match, in runes.
+	row, col, length int
+
+	// The submatch indices of the current match, in bytes of text.
+	text string
+	loc  []int
+
+	count int
+
+	// Where the replace started, which ends it after wrapping around to the top.
+	startRow, startCol int
+	wrapped            bool
+}
+
+func (e *Editor) startReplace() {
+	e.startPrompt(&Prompt{
+		label: "Replace regexp: ",
+		onDone: func(pattern []rune, ok bool) {
+			if !ok || len(pattern) == 0 {
+				return
+			}
+
+			re, err := regexp.Compile(string(pattern))
+			if err != nil {
+				e.setMessage(fmt.Sprintf("Invalid regexp: %v", err))
+				return
+			}
+
+			e.startPrompt(&Prompt{
+				label: fmt.Sprintf("Replace %s with: ", string(pattern)),
+				onDone: func(template []rune, ok bool) {
+					if !ok {
+						return
+					}
+
+					rp := &replace{
+						re:       re,
+						template: string(template),
+						startRow: e.crow + e.scroolrow,
+						startCol: e.ccol,
+					}
+					e.history.beginGroup(e.crow+e.scroolrow, e.ccol)
+					e.nextReplace(rp, e.crow+e.scroolrow, e.ccol)
+				},
+			})
+		},
+	})
+}
+
+// nextReplace finds the next match at or after (row, col) and asks what to do with it.
+func (e *Editor) nextReplace(rp *replace, row, col int) {
+	if !e.findNext(rp, row, col) {
+		e.finishReplace(rp)
+		return
+	}
+
+	e.search = &search{row: rp.row, col: rp.col, length: rp.length}
+	e.refreshAllRows()
+	e.jumpTo(rp.row, rp.col)
+
+	e.startPrompt(&Prompt{
+		label: "Replace? (y)es / (n)o / (a)ll / (q)uit",
+		onKey: func(key rune) bool {
+			switch key {
+			case 'y', ' ':
+				e.replaceMatch(rp)
+				e.nextReplace(rp, rp.row, rp.col)
+
+			case 'n', BackSpace:
+				e.nextReplace(rp, rp.row, rp.col+1)
+
+			case 'a', '!':
+				e.replaceMatch(rp)
+				for e.findNext(rp, rp.row, rp.col) {
+					e.replaceMatch(rp)
+				}
+				e.finishReplace(rp)
+
+			case 'q', Enter, Escape, ControlG, ControlC:
+				e.finishReplace(rp)
+			}
+			return true
+		},
+	})
+}
+
+// findNext finds the next match at or after (row, col), wrapping around to the top
+// of the buffer once, and stopping before where the replace started.
+func (e *Editor) findNext(rp *replace, row, col int) bool {
+	if !rp.wrapped {
+		if e.findRegexp(rp, row, col) {
+			return true
+		}
+		rp.wrapped = true
+		row, col = 0, 0
+	}
+
+	if !e.findRegexp(rp, row, col) {
+		return false
+	}
+	return rp.row < rp.startRow || (rp.row == rp.startRow && rp.col < rp.startCol)
+}
+
+// findRegexp finds the first match at or after (row, col), and stores it to rp.
+func (e *Editor) findRegexp(rp *replace, row, col int) bool {
+	for ; row < e.n; row++ {
+		runes := e.rows[row].visibleRunes()
+		if col > len(runes) {
+			col = 0
+			continue
+		}
+
+		text := string(runes)
+		if loc := rp.firstMatch(text, len(string(runes[:col]))); loc != nil {
+			rp.row, rp.col = row, utf8.RuneCountInString(text[:loc[0]])
+			rp.length = utf8.RuneCountInString(text[loc[0]:loc[1]])
+			rp.text, rp.loc = text, loc
+			return true
+		}
+
+		col = 0
+	}
+
+	return false
+}
+
+// firstMatch returns the submatch indices of the first match in text starting at
+// or after the byte offset off, or nil. FindAll doesn't return the matches which
+// overlap an earlier one, so they are looked for where they can be.
+func (rp *replace) firstMatch(text string, off int) []int {
+	for _, loc := range rp.re.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] >= off {
+			return loc
+		}
+		if loc[1] < off {
+			continue
+		}
+
+		for i := off; i <= loc[1]; {
+			if m := rp.matchAt(text, i); m != nil {
+				return m
+			}
+			_, size := utf8.DecodeRuneInString(text[i:])
+			if size == 0 {
+				break
+			}
+			i += size
+		}
+	}
+	return nil
+}
+
+// matchAt returns the submatch indices of the match starting at the byte offset i
+// of text, which is not 0, or nil. The rune before i is matched too, so that ^ and
+// \b see it.
+func (rp *replace) matchAt(text string, i int) []int {
+	if rp.after == nil {
+		re, err := syntax.Parse(rp.re.String(), syntax.Perl)
+		if err != nil {
+			return nil
+		}
+		rp.after = regexp.MustCompile((&syntax.Regexp{Op: syntax.OpConcat, Sub: []*syntax.Regexp{
+			{Op: syntax.OpBeginText},
+			{Op: syntax.OpAnyChar},
+			re,
+		}}).String())
+	}
+
+	_, size := utf8.DecodeLastRuneInString(text[:i])
+	from := i - size
+	loc := rp.after.FindStringSubmatchIndex(text[from:])
+	if loc == nil {
+		return nil
+	}
+
+	for j := range loc {
+		if loc[j] >= 0 {
+			loc[j] += from
+		}
+	}
+	loc[0] = i
+	return loc
+}
+
+// replaceMatch replaces the current match, and moves rp to just after the replacement.
+func (e *Editor) replaceMatch(rp *replace) {
+	runes := e.rows[rp.row].chars.Runes()
+	replacement := []rune(string(rp.re.ExpandString(nil, rp.template, rp.text, rp.loc)))
+
+	newRunes := append([]rune{}, runes[:rp.col]...)
+	newRunes = append(newRunes, replacement...)
+	newRunes = append(newRunes, runes[rp.col+rp.length:]...)
+	e.replaceRune(rp.row, newRunes)
+
+	if rp.wrapped && rp.row == rp.startRow {
+		// The text where the replace started moves with the replacement before it.
+		rp.startCol += len(replacement) - rp.length
+	}
+
+	rp.col += len(replacement)
+	if rp.length == 0 {
+		// Don't match the same empty string again.
+		rp.col += 1
+	}
+	rp.count += 1
+}
+
+func (e *Editor) finishReplace(rp *replace) {
+	e.search = nil
+	e.closePrompt()
+
+	row, col := e.crow+e.scroolrow, e.ccol
+	e.refreshAllRows()
+	e.jumpTo(row, col)
+	e.history.endGroup(row, col)
+
+	e.setMessage(fmt.Sprintf("Replaced %d occurrence(s)", rp.count))
+}
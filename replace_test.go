@@ -0,0 +1,134 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestFindRegexp(t *testing.T) {
+	e := makeEditor("foo(1) bar(2)\nあい(3)\n")
+	rp := &replace{re: regexp.MustCompile(`(\w+)\((\d)\)`), template: "$2:$1"}
+
+	assert.True(t, e.findRegexp(rp, 0, 0))
+	assert.Equal(t, 0, rp.row)
+	assert.Equal(t, 0, rp.col)
+	assert.Equal(t, 6, rp.length)
+	assert.Equal(t, "1:foo", string(rp.re.ExpandString(nil, rp.template, rp.text, rp.loc)))
+
+	// A match may overlap the one before.
+	assert.True(t, e.findRegexp(rp, 0, 1))
+	assert.Equal(t, 0, rp.row)
+	assert.Equal(t, 1, rp.col)
+	assert.Equal(t, 5, rp.length)
+	assert.Equal(t, "1:oo", string(rp.re.ExpandString(nil, rp.template, rp.text, rp.loc)))
+
+	assert.True(t, e.findRegexp(rp, 0, 6))
+	assert.Equal(t, 0, rp.row)
+	assert.Equal(t, 7, rp.col)
+
+	// The column is counted in runes after multi-byte characters.
+	rp.re = regexp.MustCompile(`い\((\d)\)`)
+	assert.True(t, e.findRegexp(rp, 0, 8))
+	assert.Equal(t, 1, rp.row)
+	assert.Equal(t, 1, rp.col)
+	assert.Equal(t, 4, rp.length)
+
+	assert.False(t, e.findRegexp(rp, 1, 2))
+}
+
+func typeKeys(e *Editor, keys ...rune) {
+	for _, r := range keys {
+		e.interpretKey(r)
+	}
+}
+
+// startReplace starts replacing pattern with template, and returns at the first question.
+func startReplace(e *Editor, pattern, template string) {
+	typeKeys(e, ControlT)
+	typeKeys(e, []rune(pattern)...)
+	typeKeys(e, Enter)
+	typeKeys(e, []rune(template)...)
+	typeKeys(e, Enter)
+}
+
+func bufferText(e *Editor) string {
+	text := ""
+	for i := 0; i < e.n; i++ {
+		text += e.rows[i].chars.RunesString()
+	}
+	return text
+}
+
+func TestReplaceMatch(t *testing.T) {
+	e := makeEditor("x foo(1) y\n")
+	attachScreen(e, 80, 12)
+	rp := &replace{re: regexp.MustCompile(`(\w+)\((\d)\)`), template: "$2=${1}!"}
+
+	assert.True(t, e.findRegexp(rp, 0, 0))
+	e.replaceMatch(rp)
+	assert.Equal(t, "x 1=foo! y\n", bufferText(e))
+	assert.Equal(t, 8, rp.col)
+	assert.Equal(t, 1, rp.count)
+}
+
+func TestReplace_Keys(t *testing.T) {
+	e := makeEditor("a1 a2\na3\n")
+	attachScreen(e, 80, 12)
+
+	// n skips a match, y replaces it, and q stops.
+	startReplace(e, `a(\d)`, "b$1")
+	typeKeys(e, 'n', 'y', 'q')
+	assert.Equal(t, "a1 b2\na3\n", bufferText(e))
+	assert.Nil(t, e.prompt)
+
+	// a replaces the rest at once.
+	e.jumpTo(0, 0)
+	startReplace(e, `a(\d)`, "c$1")
+	typeKeys(e, 'a')
+	assert.Equal(t, "c1 b2\nc3\n", bufferText(e))
+	assert.Nil(t, e.prompt)
+
+	// A single undo reverts the whole replace.
+	typeKeys(e, ControlZ)
+	assert.Equal(t, "a1 b2\na3\n", bufferText(e))
+}
+
+func TestReplace_Overlapping(t *testing.T) {
+	e := makeEditor("aaa\n")
+	attachScreen(e, 80, 12)
+
+	// The match after a skipped one may overlap it.
+	startReplace(e, "aa", "b")
+	typeKeys(e, 'n', 'y')
+	assert.Equal(t, "ab\n", bufferText(e))
+	assert.Nil(t, e.prompt)
+
+	// ^ and \b still see the text before the skipped match.
+	e = makeEditor("aa ab\n")
+	attachScreen(e, 80, 12)
+	startReplace(e, `^a|\ba`, "x")
+	typeKeys(e, 'n', 'y')
+	assert.Equal(t, "aa xb\n", bufferText(e))
+	assert.Nil(t, e.prompt)
+}
+
+func TestReplace_WrapAround(t *testing.T) {
+	e := makeEditor("foo\nfoo foo\nfoo\n")
+	attachScreen(e, 80, 12)
+	e.jumpTo(1, 4)
+
+	startReplace(e, "foo", "barbaz")
+	typeKeys(e, 'a')
+	assert.Equal(t, "barbaz\nbarbaz barbaz\nbarbaz\n", bufferText(e))
+	assert.Equal(t, "Replaced 4 occurrence(s)", e.screen.(*MemoryScreen).RowText(11)[:24])
+
+	// The matches before the cursor come after the ones below it, and each is asked once.
+	e = makeEditor("foo\nfoo foo\n")
+	attachScreen(e, 80, 12)
+	e.jumpTo(1, 4)
+	startReplace(e, "foo", "x")
+	typeKeys(e, 'y', 'y', 'n')
+	assert.Equal(t, "x\nfoo x\n", bufferText(e))
+	assert.Nil(t, e.prompt)
+}
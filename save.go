@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Saving
+//
+// The buffer is written to a temporary file next to the original, which is
+// synced and renamed over it, so that a crash leaves either the old or the new
+// content, never a truncated file. The new file gets the permissions and the
+// owner of the old one, and a symbolic link is kept by saving to its target.
+// If the owner can't be given, the file is saved anyway and the message says so.
+// A new file gets 0666 less the umask, like one created by other programs.
+
+func (e *Editor) save() error {
+	err := saveFile(e.filePath, e.rows, e.encoding, e.eol)
+	if err != nil && !isChownError(err) {
+		e.setMessage("Save failed: " + err.Error())
+		return err
+	}
+
+	e.history.markSaved(e.crow+e.scroolrow, e.ccol)
+	if err != nil {
+		e.setMessage("Saved, but the owner couldn't be kept: " + err.Error())
+		return nil
+	}
+	e.setMessage("Saved!")
+	return nil
+}
+
+// saveFile writes the rows to filePath, starting with a BOM if encoding is
+// "UTF-8 BOM", and ending them with CRLF if eol is "CRLF".
+// A *chownError means the content is saved, but not with the original owner.
+func saveFile(filePath string, rows []*Row, encoding, eol string) error {
+	var buf []byte
+	if encoding == "UTF-8 BOM" {
+		buf = append(buf, utf8BOM...)
+	}
+
+	for _, r := range rows {
+		runes := r.chars.Runes()
+		if eol == "CRLF" && len(runes) > 0 && runes[len(runes)-1] == '\n' {
+			buf = append(buf, encodeRunes(runes[:len(runes)-1])...)
+			buf = append(buf, "\r\n"...)
+			continue
+		}
+		buf = append(buf, encodeRunes(runes)...)
+	}
+
+	path, err := filepath.EvalSymlinks(filePath)
+	if os.IsNotExist(err) {
+		// A new file, or a link to one.
+		path, err = resolveNewFile(filePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return writeFileAtomic(path, buf, newFilePerm(), nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, buf, info.Mode().Perm(), info.Sys())
+}
+
+// newFilePerm returns the permissions of a new file, 0666 less the umask.
+func newFilePerm() os.FileMode {
+	// The umask can only be read by setting it.
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return 0666 &^ os.FileMode(mask)
+}
+
+// resolveNewFile returns the path to create for filePath, which doesn't exist
+// itself, following it if it is a dangling symbolic link.
+func resolveNewFile(filePath string) (string, error) {
+	target, err := os.Readlink(filePath)
+	if err != nil {
+		return filePath, nil
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(filePath), target)
+	}
+	return target, nil
+}
+
+// chownError marks the failure to give the temporary file the original owner.
+type chownError struct {
+	err error
+}
+
+func (e *chownError) Error() string { return e.err.Error() }
+func (e *chownError) Unwrap() error { return e.err }
+
+func isChownError(err error) bool {
+	_, ok := err.(*chownError)
+	return ok
+}
+
+// chownLike gives f the owner in sys, if it is different.
+func chownLike(f *os.File, sys interface{}) error {
+	want, ok := sys.(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if got, ok := info.Sys().(*syscall.Stat_t); ok && got.Uid == want.Uid && got.Gid == want.Gid {
+		return nil
+	}
+
+	if err := f.Chown(int(want.Uid), int(want.Gid)); err != nil {
+		return &chownError{err}
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file. sys is the
+// stat of the original file, whose owner is kept, or nil. If the owner can't be
+// given, path is replaced all the same and a *chownError is returned.
+func writeFileAtomic(path string, data []byte, perm os.FileMode, sys interface{}) (err error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil && !isChownError(err) {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	chownErr := chownLike(tmp, sys)
+	if chownErr != nil && !isChownError(chownErr) {
+		return chownErr
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Make the rename durable too. Some file systems can't sync a directory,
+	// which is not worth failing the save for.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return chownErr
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestSaveFile_KeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.sh")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("old\n"), 0755))
+
+	e := makeEditor("new\n")
+	assert.NoError(t, saveFile(path, e.rows, "UTF-8", "LF"))
+
+	text, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "new\n", string(text))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	// No temporary file is left.
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestSaveFile_NewFile(t *testing.T) {
+	old := syscall.Umask(027)
+	defer syscall.Umask(old)
+
+	path := filepath.Join(t.TempDir(), "new.txt")
+	e := makeEditor("new\n")
+	assert.NoError(t, saveFile(path, e.rows, "UTF-8", "LF"))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestSaveFile_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	link := filepath.Join(dir, "link.txt")
+	assert.NoError(t, ioutil.WriteFile(target, []byte("old\n"), 0644))
+	assert.NoError(t, os.Symlink("target.txt", link))
+
+	e := makeEditor("new\n")
+	assert.NoError(t, saveFile(link, e.rows, "UTF-8", "LF"))
+
+	dest, err := os.Readlink(link)
+	assert.NoError(t, err)
+	assert.Equal(t, "target.txt", dest)
+	text, err := ioutil.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "new\n", string(text))
+
+	// A dangling link creates its target.
+	assert.NoError(t, os.Symlink("new.txt", filepath.Join(dir, "dangling.txt")))
+	assert.NoError(t, saveFile(filepath.Join(dir, "dangling.txt"), e.rows, "UTF-8", "LF"))
+	text, err = ioutil.ReadFile(filepath.Join(dir, "new.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "new\n", string(text))
+}
+
+func TestSave_Error(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "a.txt")
+
+	e := makeEditor("abc\n")
+	s := attachScreen(e, 80, 5)
+	e.filePath = path
+	e.interpretKey('x')
+	e.interpretKey(ControlS)
+
+	assert.True(t, strings.HasPrefix(s.RowText(4), "Save failed: "))
+	assert.True(t, e.modified())
+}
+
+func TestWriteFileAtomic_ChownError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can give any owner")
+	}
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("old\n"), 0644))
+
+	err := writeFileAtomic(path, []byte("new\n"), 0644, &syscall.Stat_t{Uid: 0, Gid: 0})
+	assert.True(t, isChownError(err))
+
+	// The content is replaced all the same.
+	text, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "new\n", string(text))
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"syscall"
+)
+
+// Screen
+//
+// The editor draws into a Screen of cells instead of writing to the terminal
+// directly. TerminalScreen compares the cells with the frame written last and
+// writes only the changed cells, with a single write per frame. MemoryScreen
+// just keeps the cells, so that tests can look at what would be shown.
+
+type Screen interface {
+	// Size returns the number of columns and rows.
+	Size() (width, height int)
+
+	// SetCell sets the cell at (row, col). Cells out of the screen are ignored.
+	// The rest of a wide cluster partly overwritten is blanked.
+	SetCell(row, col int, c cell)
+
+	MoveCursor(row, col int)
+
+	// Flush shows the cells set since the last flush.
+	Flush()
+
+	// Resize clears the screen and changes its size.
+	Resize(width, height int)
+}
+
+type cell struct {
+	text  string // a grapheme cluster, or "" for the right half of a wide one
+	color color
+	bg    color
+}
+
+func blankCell(bg color) cell {
+	return cell{text: " ", color: FgDefault, bg: bg}
+}
+
+// cellGrid is the part shared by the screens.
+type cellGrid struct {
+	width, height int
+	cells         []cell
+
+	cursorRow, cursorCol int
+}
+
+func newCellGrid(width, height int) cellGrid {
+	g := cellGrid{width: width, height: height, cells: make([]cell, width*height)}
+	for i := range g.cells {
+		g.cells[i] = blankCell(BgDefault)
+	}
+	return g
+}
+
+func (g *cellGrid) Size() (int, int) {
+	return g.width, g.height
+}
+
+func (g *cellGrid) SetCell(row, col int, c cell) {
+	if row < 0 || row >= g.height || col < 0 || col >= g.width {
+		return
+	}
+	i := row * g.width
+
+	// A half of a wide cluster left alone would shift the cells after it on the terminal.
+	if c.text != "" && g.cells[i+col].text == "" {
+		for j := col - 1; j >= 0; j-- {
+			text := g.cells[i+j].text
+			g.cells[i+j] = blankCell(g.cells[i+j].bg)
+			if text != "" {
+				break
+			}
+		}
+	}
+	for j := col + 1; j < g.width && g.cells[i+j].text == ""; j++ {
+		g.cells[i+j] = blankCell(g.cells[i+j].bg)
+	}
+
+	g.cells[i+col] = c
+}
+
+func (g *cellGrid) MoveCursor(row, col int) {
+	g.cursorRow, g.cursorCol = row, col
+}
+
+// TerminalScreen draws on the terminal at fd.
+type TerminalScreen struct {
+	cellGrid
+	fd   int
+	prev []cell // the frame written last, nil before the first flush
+}
+
+func newTerminalScreen(fd, width, height int) *TerminalScreen {
+	return &TerminalScreen{cellGrid: newCellGrid(width, height), fd: fd}
+}
+
+func (s *TerminalScreen) Flush() {
+	syscall.Write(s.fd, s.render())
+}
+
+func (s *TerminalScreen) Resize(width, height int) {
+	s.cellGrid = newCellGrid(width, height)
+	s.prev = nil
+}
+
+// render returns the output which updates the terminal from the previous frame to this one.
+func (s *TerminalScreen) render() []byte {
+	var buf bytes.Buffer
+
+	if s.prev == nil {
+		// The terminal is in an unknown state, so clear it and draw every cell.
+		buf.WriteString("\033[0m\033[2J")
+		s.prev = make([]cell, len(s.cells))
+	}
+
+	drawn := false
+	curRow, curCol := -1, -1
+	var curColor, curBg color
+
+	for row := 0; row < s.height; row++ {
+		for col := 0; col < s.width; col++ {
+			i := row*s.width + col
+			c := s.cells[i]
+			if c == s.prev[i] || c.text == "" {
+				continue
+			}
+
+			if !drawn {
+				buf.WriteString("\033[?25l") // hide the cursor while drawing
+				drawn = true
+			}
+			if row != curRow || col != curCol {
+				fmt.Fprintf(&buf, "\033[%d;%dH", row+1, col+1) // 0-origin to 1-origin
+			}
+			if c.color != curColor || c.bg != curBg {
+				fmt.Fprintf(&buf, "\033[0;%d;%dm", c.color, c.bg)
+				curColor, curBg = c.color, c.bg
+			}
+			buf.WriteString(c.text)
+
+			curRow, curCol = row, col+1
+			for curCol < s.width && s.cells[row*s.width+curCol].text == "" {
+				curCol += 1
+			}
+		}
+	}
+
+	if drawn {
+		buf.WriteString("\033[0m")
+	}
+	fmt.Fprintf(&buf, "\033[%d;%dH", s.cursorRow+1, s.cursorCol+1)
+	if drawn {
+		buf.WriteString("\033[?25h")
+	}
+
+	copy(s.prev, s.cells)
+	return buf.Bytes()
+}
+
+// MemoryScreen keeps the cells in memory only.
+type MemoryScreen struct {
+	cellGrid
+}
+
+func newMemoryScreen(width, height int) *MemoryScreen {
+	return &MemoryScreen{cellGrid: newCellGrid(width, height)}
+}
+
+func (s *MemoryScreen) Flush() {}
+
+func (s *MemoryScreen) Resize(width, height int) {
+	s.cellGrid = newCellGrid(width, height)
+}
+
+func (s *MemoryScreen) Cell(row, col int) cell {
+	return s.cells[row*s.width+col]
+}
+
+// RowText returns the text shown in the row, including the trailing spaces.
+func (s *MemoryScreen) RowText(row int) string {
+	text := ""
+	for col := 0; col < s.width; col++ {
+		text += s.cells[row*s.width+col].text
+	}
+	return text
+}
+
+func (s *MemoryScreen) Cursor() (row, col int) {
+	return s.cursorRow, s.cursorCol
+}
+
+func clearRow(s Screen, row int, bg color) {
+	width, _ := s.Size()
+	for col := 0; col < width; col++ {
+		s.SetCell(row, col, blankCell(bg))
+	}
+}
+
+// drawRunes draws runes from (row, col), clipped at the right edge, and returns the
+// column after them. A grapheme cluster is drawn with the color of its first rune,
+// or FgDefault if colors doesn't cover it. A background color in colors is drawn
+// behind black text instead of bg. Tab stops are counted from col.
+func drawRunes(s Screen, row, col int, runes []rune, colors []color, bg color) int {
+	width, height := s.Size()
+	if row < 0 || row >= height {
+		return col
+	}
+
+	start := col
+	for i := 0; i < len(runes); {
+		end := clusterEnd(runes, i)
+		cluster := runes[i:end]
+		w := cellWidth(cluster, col-start)
+
+		c, b := color(FgDefault), bg
+		if i < len(colors) {
+			c = colors[i]
+		}
+		if c >= BgBlack && c < BgDefault {
+			c, b = FgBlack, c
+		}
+		i = end
+
+		if w == 0 {
+			// Control characters, such as the newline, aren't drawn.
+			continue
+		}
+		if col+w > width {
+			break
+		}
+
+		if cluster[0] == '\t' {
+			for j := 0; j < w; j++ {
+				s.SetCell(row, col+j, cell{text: " ", color: c, bg: b})
+			}
+			col += w
+			continue
+		}
+
+		text := string(cluster)
+		if isRawByte(cluster[0]) {
+			text = "\uFFFD"
+		}
+
+		s.SetCell(row, col, cell{text: text, color: c, bg: b})
+		for j := 1; j < w; j++ {
+			s.SetCell(row, col+j, cell{color: c, bg: b})
+		}
+		col += w
+	}
+
+	return col
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDrawRunes(t *testing.T) {
+	s := newMemoryScreen(6, 2)
+
+	col := drawRunes(s, 0, 1, []rune("aあ\n"), []color{FgCyan, FgGreen}, BgDefault)
+	assert.Equal(t, 4, col)
+	assert.Equal(t, " aあ  ", s.RowText(0))
+	assert.Equal(t, cell{text: "a", color: FgCyan, bg: BgDefault}, s.Cell(0, 1))
+	assert.Equal(t, cell{text: "あ", color: FgGreen, bg: BgDefault}, s.Cell(0, 2))
+	assert.Equal(t, cell{color: FgGreen, bg: BgDefault}, s.Cell(0, 3))
+
+	// A wide character is not drawn across the right edge.
+	col = drawRunes(s, 1, 0, []rune("abcdeあ"), nil, BgCyan)
+	assert.Equal(t, 5, col)
+	assert.Equal(t, "abcde ", s.RowText(1))
+	assert.Equal(t, blankCell(BgDefault), s.Cell(1, 5))
+
+	// A combining mark is kept with its base, and invalid bytes are replaced.
+	clearRow(s, 0, BgDefault)
+	drawRunes(s, 0, 0, []rune{'e', 0x301, rawByteMin}, nil, BgDefault)
+	assert.Equal(t, "e\u0301", s.Cell(0, 0).text)
+	assert.Equal(t, "\uFFFD", s.Cell(0, 1).text)
+
+	// Rows out of the screen are ignored.
+	assert.Equal(t, 0, drawRunes(s, -1, 0, []rune("abc"), nil, BgDefault))
+	assert.Equal(t, 0, drawRunes(s, 2, 0, []rune("abc"), nil, BgDefault))
+}
+
+func TestTerminalScreen_Render(t *testing.T) {
+	s := newTerminalScreen(-1, 4, 2)
+	drawRunes(s, 0, 0, []rune("ab"), nil, BgDefault)
+
+	// The first frame clears the terminal and draws every cell.
+	out := string(s.render())
+	assert.Equal(t, "\033[0m\033[2J\033[?25l\033[1;1H\033[0;39;49mab  \033[2;1H    \033[0m\033[1;1H\033[?25h", out)
+
+	// Nothing but the cursor is written if nothing changed.
+	s.MoveCursor(1, 2)
+	assert.Equal(t, "\033[2;3H", string(s.render()))
+
+	// Only the changed cells are written.
+	drawRunes(s, 0, 1, []rune("x"), nil, BgDefault)
+	drawRunes(s, 1, 2, []rune("y"), []color{FgGreen}, BgDefault)
+	out = string(s.render())
+	assert.Equal(t, "\033[?25l\033[1;2H\033[0;39;49mx\033[2;3H\033[0;32;49my\033[0m\033[2;3H\033[?25h", out)
+
+	// Everything is drawn again after resizing.
+	s.Resize(2, 1)
+	out = string(s.render())
+	assert.Equal(t, "\033[0m\033[2J\033[?25l\033[1;1H\033[0;39;49m  \033[0m\033[1;1H\033[?25h", out)
+}
+
+func TestTerminalScreen_RenderWide(t *testing.T) {
+	s := newTerminalScreen(-1, 4, 1)
+	s.render()
+
+	// The cursor moves over both cells of a wide character.
+	drawRunes(s, 0, 0, []rune("あb"), nil, BgDefault)
+	out := string(s.render())
+	assert.Equal(t, "\033[?25l\033[1;1H\033[0;39;49mあb\033[0m\033[1;1H\033[?25h", out)
+
+	drawRunes(s, 0, 0, []rune("cd"), nil, BgDefault)
+	out = string(s.render())
+	assert.Equal(t, "\033[?25l\033[1;1H\033[0;39;49mcd\033[0m\033[1;1H\033[?25h", out)
+}
+
+func TestTerminalScreen_RenderSplitWide(t *testing.T) {
+	e := makeEditor("abcdefghあijklmnopqrstuvwxyz\n")
+	s := newTerminalScreen(-1, 10, 3)
+	e.screen = s
+	e.initScreen()
+	s.render()
+
+	// The indicator hides the left half of あ, so the right half is blanked.
+	e.scroolcol = 8
+	e.setColPos(12)
+	e.redrawAllRows()
+	out := string(s.render())
+	assert.Contains(t, out, "<\033[0;39;49m ijklmn")
+
+	// The cursor is on l, where the terminal draws it.
+	assert.True(t, strings.HasSuffix(out, "\033[1;6H\033[?25h"))
+}
+
+func TestSetCell_SplitWide(t *testing.T) {
+	s := newMemoryScreen(5, 1)
+
+	drawRunes(s, 0, 0, []rune("あいu"), nil, BgDefault)
+	s.SetCell(0, 1, cell{text: "x", color: FgDefault, bg: BgDefault})
+	assert.Equal(t, " xいu", s.RowText(0))
+
+	s.SetCell(0, 2, cell{text: "y", color: FgDefault, bg: BgDefault})
+	assert.Equal(t, " xy u", s.RowText(0))
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"unicode"
+)
+
+// Incremental search
+//
+// The cursor jumps to the first match after the original position as the query is typed.
+// The matching is case-insensitive unless the query has an upper case letter. All the
+// matches on the screen are highlighted, and the current one is in reverse video.
+
+type search struct {
+	query []rune
+
+	// The current match. row is -1 if there is no match.
+	row, col, length int
+
+	// The cursor position before searching, restored on cancel.
+	origRow, origCol, origScroolrow int
+}
+
+func (e *Editor) startSearch() {
+	s := &search{
+		row:           -1,
+		origRow:       e.crow,
+		origCol:       e.ccol,
+		origScroolrow: e.scroolrow,
+	}
+	e.search = s
+
+	e.startPrompt(&Prompt{
+		label: "Search (Arrows = Prev/Next, ESC = Cancel): ",
+		onKey: func(key rune) bool {
+			switch key {
+			case ArrowDown, ArrowRight, ControlN:
+				e.searchNext(true)
+				return true
+			case ArrowUp, ArrowLeft, ControlP:
+				e.searchNext(false)
+				return true
+			}
+			return false
+		},
+		onChange: func(input []rune) {
+			s.query = input
+			e.searchFrom(s.origScroolrow+s.origRow, s.origCol, true)
+		},
+		onDone: func(input []rune, ok bool) {
+			e.search = nil
+			if !ok {
+				e.scroolrow = s.origScroolrow
+				e.refreshAllRows()
+				e.crow = s.origRow
+				e.setColPos(s.origCol)
+				return
+			}
+
+			// Redraw the rows without the highlight.
+			row, col := e.crow+e.scroolrow, e.ccol
+			e.refreshAllRows()
+			e.jumpTo(row, col)
+		},
+	})
+}
+
+// searchNext moves to the next (or previous) match from the current one.
+func (e *Editor) searchNext(forward bool) {
+	s := e.search
+	if s.row < 0 {
+		e.searchFrom(e.crow+e.scroolrow, e.ccol, forward)
+		return
+	}
+
+	if forward {
+		e.searchFrom(s.row, s.col+1, true)
+	} else {
+		e.searchFrom(s.row, s.col-1, false)
+	}
+}
+
+// searchFrom moves the cursor to the match nearest to (row, col) in the direction.
+func (e *Editor) searchFrom(row, col int, forward bool) {
+	s := e.search
+
+	if len(s.query) == 0 {
+		s.row = -1
+	} else if r, c, ok := e.find(s.query, row, col, forward); ok {
+		s.row, s.col, s.length = r, c, len(s.query)
+	} else {
+		s.row = -1
+	}
+
+	if s.row < 0 {
+		e.refreshAllRows()
+		e.jumpTo(s.origScroolrow+s.origRow, s.origCol)
+		return
+	}
+
+	e.refreshAllRows()
+	e.jumpTo(s.row, s.col)
+}
+
+// find returns the position of query nearest to (row, col) in the direction,
+// wrapping around the buffer.
+func (e *Editor) find(query []rune, row, col int, forward bool) (int, int, bool) {
+	fold := !hasUpper(query)
+
+	for i := 0; i <= e.n; i++ {
+		var r int
+		if forward {
+			r = (row + i) % e.n
+		} else {
+			r = ((row-i)%e.n + e.n) % e.n
+		}
+		runes := e.rows[r].visibleRunes()
+
+		// The row where the search starts is searched twice:
+		// first after (before) col, then the rest after wrapping around.
+		if forward {
+			from := 0
+			if i == 0 {
+				from = col
+			}
+			to := len(runes)
+			if i == e.n {
+				to = col
+			}
+			if c := indexRunes(runes, query, from, to, fold); c >= 0 {
+				return r, c, true
+			}
+		} else {
+			from := len(runes)
+			if i == 0 {
+				from = col
+			}
+			to := 0
+			if i == e.n {
+				to = col + 1
+			}
+			if c := lastIndexRunes(runes, query, from, to, fold); c >= 0 {
+				return r, c, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+func hasUpper(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRunes(runes []rune, query []rune, at int, fold bool) bool {
+	if at < 0 || at+len(query) > len(runes) {
+		return false
+	}
+
+	for i, q := range query {
+		r := runes[at+i]
+		if fold {
+			r, q = unicode.ToLower(r), unicode.ToLower(q)
+		}
+		if r != q {
+			return false
+		}
+	}
+	return true
+}
+
+// indexRunes returns the first index in [from, to) where query matches runes, or -1.
+func indexRunes(runes []rune, query []rune, from, to int, fold bool) int {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(runes) {
+		to = len(runes)
+	}
+
+	for i := from; i < to; i++ {
+		if matchRunes(runes, query, i, fold) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastIndexRunes returns the last index in [to, from] where query matches runes, or -1.
+func lastIndexRunes(runes []rune, query []rune, from, to int, fold bool) int {
+	if from > len(runes)-len(query) {
+		from = len(runes) - len(query)
+	}
+	if to < 0 {
+		to = 0
+	}
+
+	for i := from; i >= to; i-- {
+		if matchRunes(runes, query, i, fold) {
+			return i
+		}
+	}
+	return -1
+}
+
+// highlightMatch marks the matches in the row, and the current one with reverse video.
+func (e *Editor) highlightMatch(row int, colors []color, n int) []color {
+	s := e.search
+	if s == nil {
+		return colors
+	}
+
+	if len(s.query) > 0 {
+		runes := e.rows[row].visibleRunes()
+		fold := !hasUpper(s.query)
+		for i := 0; i < len(runes); i++ {
+			if matchRunes(runes, s.query, i, fold) {
+				colors = paintColors(colors, n, i, i+len(s.query), BgYellow)
+			}
+		}
+	}
+
+	if s.row == row && s.length > 0 {
+		colors = reverseColors(colors, n, s.col, s.col+s.length)
+	}
+	return colors
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIndexRunes(t *testing.T) {
+	runes := []rune("abcabc")
+	assert.Equal(t, 0, indexRunes(runes, []rune("abc"), 0, 6, false))
+	assert.Equal(t, 3, indexRunes(runes, []rune("abc"), 1, 6, false))
+	assert.Equal(t, -1, indexRunes(runes, []rune("abc"), 4, 6, false))
+	assert.Equal(t, -1, indexRunes(runes, []rune("ABC"), 0, 6, false))
+	assert.Equal(t, 0, indexRunes(runes, []rune("ABC"), 0, 6, true))
+
+	assert.Equal(t, 3, lastIndexRunes(runes, []rune("abc"), 6, 0, false))
+	assert.Equal(t, 0, lastIndexRunes(runes, []rune("abc"), 2, 0, false))
+	assert.Equal(t, -1, lastIndexRunes(runes, []rune("abc"), 2, 1, false))
+}
+
+func TestFind_Forward(t *testing.T) {
+	e := makeEditor("foo bar\nbaz\nbar foo\n")
+
+	row, col, ok := e.find([]rune("bar"), 0, 0, true)
+	assert.True(t, ok)
+	assert.Equal(t, 0, row)
+	assert.Equal(t, 4, col)
+
+	row, col, ok = e.find([]rune("bar"), 0, 5, true)
+	assert.True(t, ok)
+	assert.Equal(t, 2, row)
+	assert.Equal(t, 0, col)
+
+	// Wrap around the end of the buffer.
+	row, col, ok = e.find([]rune("foo"), 2, 5, true)
+	assert.True(t, ok)
+	assert.Equal(t, 0, row)
+	assert.Equal(t, 0, col)
+
+	_, _, ok = e.find([]rune("qux"), 0, 0, true)
+	assert.False(t, ok)
+}
+
+func TestFind_Backward(t *testing.T) {
+	e := makeEditor("foo bar\nbaz\nbar foo\n")
+
+	row, col, ok := e.find([]rune("ba"), 2, 0, false)
+	assert.True(t, ok)
+	assert.Equal(t, 2, row)
+	assert.Equal(t, 0, col)
+
+	row, col, ok = e.find([]rune("ba"), 1, -1, false)
+	assert.True(t, ok)
+	assert.Equal(t, 0, row)
+	assert.Equal(t, 4, col)
+
+	// Wrap around the start of the buffer.
+	row, col, ok = e.find([]rune("foo"), 0, -1, false)
+	assert.True(t, ok)
+	assert.Equal(t, 2, row)
+	assert.Equal(t, 4, col)
+}
+
+func TestFind_SmartCase(t *testing.T) {
+	e := makeEditor("Hello\nhello\n")
+
+	row, _, ok := e.find([]rune("hello"), 0, 0, true)
+	assert.True(t, ok)
+	assert.Equal(t, 0, row)
+
+	row, _, ok = e.find([]rune("hello"), 0, 1, true)
+	assert.True(t, ok)
+	assert.Equal(t, 1, row)
+
+	row, _, ok = e.find([]rune("Hello"), 0, 1, true)
+	assert.True(t, ok)
+	assert.Equal(t, 0, row)
+}
+
+func TestSearch_HighlightAll(t *testing.T) {
+	e := makeEditor("foo bar foo\nFoo\n")
+	s := attachScreen(e, 20, 5)
+
+	for _, r := range []rune{ControlR, 'f', 'o', 'o'} {
+		e.interpretKey(r)
+	}
+
+	// The current match is reversed, and the others have a background.
+	assert.Equal(t, cell{text: "f", color: Reverse, bg: BgDefault}, s.Cell(0, 0))
+	assert.Equal(t, cell{text: "b", color: FgDefault, bg: BgDefault}, s.Cell(0, 4))
+	assert.Equal(t, cell{text: "f", color: FgBlack, bg: BgYellow}, s.Cell(0, 8))
+	assert.Equal(t, cell{text: "F", color: FgBlack, bg: BgYellow}, s.Cell(1, 0))
+
+	// The highlight is gone after searching.
+	e.interpretKey(Enter)
+	assert.Equal(t, BgDefault, int(s.Cell(0, 8).bg))
+}
@@ -0,0 +1,98 @@
+package main
+
+// Selection
+//
+// Like Emacs, Ctrl+Space sets the mark, and the region between the mark and the
+// cursor is selected as the cursor moves. The region is copied or cut into the
+// kill ring, and pasted by yanking it. See kill_ring.go.
+
+type mark struct {
+	row, col int
+}
+
+func (e *Editor) toggleMark() {
+	if e.mark != nil {
+		e.clearMark()
+		return
+	}
+
+	e.mark = &mark{row: e.crow + e.scroolrow, col: e.ccol}
+	e.setMessage("Mark set")
+}
+
+func (e *Editor) clearMark() {
+	if e.mark == nil {
+		return
+	}
+
+	e.mark = nil
+	e.redrawAllRows()
+}
+
+// region returns the start and end of the region, or ok = false if the mark isn't set.
+func (e *Editor) region() (startRow, startCol, endRow, endCol int, ok bool) {
+	if e.mark == nil {
+		return 0, 0, 0, 0, false
+	}
+
+	// The rows may have been deleted since the mark was set.
+	markRow, markCol := e.mark.row, e.mark.col
+	if markRow >= e.n {
+		markRow = e.n - 1
+	}
+	if markCol > e.rows[markRow].visibleLen() {
+		markCol = e.rows[markRow].visibleLen()
+	}
+
+	row, col := e.crow+e.scroolrow, e.ccol
+	if markRow < row || (markRow == row && markCol < col) {
+		return markRow, markCol, row, col, true
+	}
+	return row, col, markRow, markCol, true
+}
+
+// highlightRegion marks the part of the region in the row with reverse video.
+func (e *Editor) highlightRegion(row int, colors []color, n int) []color {
+	startRow, startCol, endRow, endCol, ok := e.region()
+	if !ok || row < startRow || row > endRow {
+		return colors
+	}
+
+	from, to := 0, n
+	if row == startRow {
+		from = startCol
+	}
+	if row == endRow {
+		to = endCol
+	}
+
+	if from >= to {
+		return colors
+	}
+	return reverseColors(colors, n, from, to)
+}
+
+func (e *Editor) copyRegion() {
+	startRow, startCol, endRow, endCol, ok := e.region()
+	if !ok {
+		e.setMessage("The mark is not set")
+		return
+	}
+
+	e.kill(e.textBetween(startRow, startCol, endRow, endCol), false)
+	e.clearMark()
+	e.setMessage("Copied")
+}
+
+func (e *Editor) cutRegion() {
+	startRow, startCol, endRow, endCol, ok := e.region()
+	if !ok {
+		e.setMessage("The mark is not set")
+		return
+	}
+
+	e.kill(e.deleteText(startRow, startCol, endRow, endCol), false)
+	e.mark = nil
+	e.redrawAllRows()
+	e.jumpTo(startRow, startCol)
+}
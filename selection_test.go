@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegion(t *testing.T) {
+	e := makeEditor("hello\nworld\n")
+
+	_, _, _, _, ok := e.region()
+	assert.False(t, ok)
+
+	e.mark = &mark{row: 1, col: 3}
+	e.crow, e.ccol = 0, 2
+	startRow, startCol, endRow, endCol, ok := e.region()
+	assert.True(t, ok)
+	assert.Equal(t, []int{0, 2, 1, 3}, []int{startRow, startCol, endRow, endCol})
+
+	e.crow, e.ccol = 1, 5
+	startRow, startCol, endRow, endCol, _ = e.region()
+	assert.Equal(t, []int{1, 3, 1, 5}, []int{startRow, startCol, endRow, endCol})
+
+	// The mark is clamped if the rows have been deleted.
+	e.mark = &mark{row: 10, col: 10}
+	startRow, startCol, endRow, endCol, _ = e.region()
+	assert.Equal(t, []int{1, 5, 2, 0}, []int{startRow, startCol, endRow, endCol})
+}
+
+func TestHighlightRegion(t *testing.T) {
+	e := makeEditor("hello\nworld\n")
+	e.mark = &mark{row: 0, col: 3}
+	e.crow, e.ccol = 1, 2
+
+	colors := e.highlightRegion(0, nil, 5)
+	assert.Equal(t, []color{FgDefault, FgDefault, FgDefault, Reverse, Reverse}, colors)
+
+	colors = e.highlightRegion(1, nil, 5)
+	assert.Equal(t, []color{Reverse, Reverse, FgDefault, FgDefault, FgDefault}, colors)
+
+	assert.Nil(t, e.highlightRegion(2, nil, 0))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Status bar
+//
+// The status bar shows the file name, with [+] while there are unsaved changes,
+// and on the right the cursor position, the mode, the file type, the encoding and
+// the line endings. The segments on the right are dropped from the end when the
+// screen is too narrow. It is rewritten after every event.
+
+var fileTypes = map[string]string{
+	".go":   "Go",
+	".md":   "Markdown",
+	".txt":  "Text",
+	".c":    "C",
+	".h":    "C",
+	".py":   "Python",
+	".js":   "JavaScript",
+	".ts":   "TypeScript",
+	".json": "JSON",
+	".yaml": "YAML",
+	".yml":  "YAML",
+	".toml": "TOML",
+	".sh":   "Shell",
+	".html": "HTML",
+	".css":  "CSS",
+}
+
+func fileType(filePath string) string {
+	if filepath.Base(filePath) == "Makefile" {
+		return "Makefile"
+	}
+	if t, ok := fileTypes[strings.ToLower(filepath.Ext(filePath))]; ok {
+		return t
+	}
+	return "Text"
+}
+
+// detectEncoding names the encoding of the file content b.
+func detectEncoding(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte(utf8BOM)):
+		return "UTF-8 BOM"
+	case utf8.Valid(b):
+		return "UTF-8"
+	default:
+		// Invalid bytes are kept as they are. See encoding.go.
+		return "UTF-8 (invalid bytes)"
+	}
+}
+
+// detectLineEnding names the line endings in the file content b.
+func detectLineEnding(b []byte) string {
+	lf := bytes.Count(b, []byte("\n"))
+	crlf := bytes.Count(b, []byte("\r\n"))
+	switch {
+	case crlf == 0:
+		return "LF"
+	case crlf == lf:
+		return "CRLF"
+	default:
+		return "Mixed"
+	}
+}
+
+// mode names what the keys do now.
+func (e *Editor) mode() string {
+	mode := "EDIT"
+	switch {
+	case e.search != nil:
+		mode = "SEARCH"
+	case e.prompt != nil:
+		mode = "PROMPT"
+	case e.mark != nil:
+		mode = "SELECT"
+	}
+
+	if e.wrap {
+		mode += " WRAP"
+	}
+	return mode
+}
+
+// lineCount returns the number of lines. The empty row after the last newline
+// is not a line of its own, but where the cursor goes to add one.
+func (e *Editor) lineCount() int {
+	if e.n > 1 && e.rows[e.n-1].len() == 0 {
+		return e.n - 1
+	}
+	return e.n
+}
+
+func (e *Editor) writeStatusBar() {
+	name := e.filePath
+	marker := ""
+	if e.modified() {
+		marker = " [+]"
+	}
+
+	line := e.crow + e.scroolrow + 1
+	if line > e.lineCount() {
+		// The cursor is on the empty row after the last newline.
+		line = e.lineCount()
+	}
+
+	segments := []string{
+		fmt.Sprintf("Ln %d/%d, Col %d", line, e.lineCount(), e.cursorColumn()+1),
+		e.mode(),
+		fileType(e.filePath),
+		e.encoding,
+		e.eol,
+	}
+
+	width := e.screenWidth()
+	right := strings.Join(segments, "  ")
+	for len(segments) > 1 && stringWidth(name+marker)+2+stringWidth(right) > width {
+		segments = segments[:len(segments)-1]
+		right = strings.Join(segments, "  ")
+	}
+
+	row := e.textHeight()
+	clearRow(e.screen, row, BgCyan)
+	// The name is cut to keep the marker.
+	name = truncateString(name, width-stringWidth(right)-stringWidth(marker)-1)
+	drawRunes(e.screen, row, 0, []rune(name+marker), nil, BgCyan)
+	drawRunes(e.screen, row, width-stringWidth(right), []rune(right), nil, BgCyan)
+}
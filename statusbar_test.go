@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectLineEnding(t *testing.T) {
+	assert.Equal(t, "LF", detectLineEnding([]byte("a\nb\n")))
+	assert.Equal(t, "LF", detectLineEnding([]byte("")))
+	assert.Equal(t, "CRLF", detectLineEnding([]byte("a\r\nb\r\n")))
+	assert.Equal(t, "Mixed", detectLineEnding([]byte("a\r\nb\n")))
+}
+
+func TestDetectEncoding(t *testing.T) {
+	assert.Equal(t, "UTF-8", detectEncoding([]byte("あ")))
+	assert.Equal(t, "UTF-8 BOM", detectEncoding([]byte("\xef\xbb\xbfa")))
+	assert.Equal(t, "UTF-8 (invalid bytes)", detectEncoding([]byte("a\xff")))
+}
+
+func TestWriteStatusBar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0644))
+
+	s := newMemoryScreen(120, 6)
+	e := newEditor(path, false, s)
+	statusBar := func() string { return strings.TrimSpace(s.RowText(4)) }
+
+	assert.True(t, strings.HasPrefix(statusBar(), path+" "))
+	assert.True(t, strings.HasSuffix(statusBar(), "Ln 1/3, Col 1  EDIT  Go  UTF-8  LF"))
+
+	e.interpretEvent(keyEvent{key: ArrowDown})
+	e.interpretEvent(keyEvent{key: 'x'})
+	assert.True(t, strings.HasPrefix(statusBar(), path+" [+] "))
+	assert.True(t, strings.HasSuffix(statusBar(), "Ln 2/3, Col 2  EDIT  Go  UTF-8  LF"))
+
+	e.interpretEvent(keyEvent{key: ControlSpace})
+	assert.Contains(t, statusBar(), "SELECT")
+
+	e.interpretEvent(keyEvent{key: ControlS})
+	assert.False(t, strings.Contains(statusBar(), "[+]"))
+}
+
+func TestWriteStatusBar_Col(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("日本語\n"), 0644))
+
+	s := newMemoryScreen(80, 6)
+	e := newEditor(path, false, s)
+	e.interpretEvent(keyEvent{key: ControlE})
+	assert.Contains(t, s.RowText(4), "Ln 1/1, Col 7")
+
+	// The empty row after the last newline isn't counted as a line.
+	e.interpretEvent(keyEvent{key: ArrowDown})
+	e.interpretEvent(keyEvent{key: ArrowDown})
+	assert.Contains(t, s.RowText(4), "Ln 1/1, Col 1")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"golang.org/x/sys/unix"
+	"syscall"
+	"time"
+)
+
+const (
+	enableBracketedPaste  = "\033[?2004h"
+	disableBracketedPaste = "\033[?2004l"
+
+	// Report clicks, drags and the wheel in the SGR format.
+	enableMouse  = "\033[?1002h\033[?1006h"
+	disableMouse = "\033[?1006l\033[?1002l"
+)
+
+type Terminal struct {
+	termios *unix.Termios
+}
+
+// makeRaw puts the terminal into raw mode and returns the original termios.
+// ioctlReadTermios and ioctlWriteTermios are defined per platform.
+func makeRaw(fd int) *unix.Termios {
+	termios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		panic(err)
+	}
+
+	original := *termios
+
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, termios); err != nil {
+		panic(err)
+	}
+
+	return &original
+}
+
+func (e *Editor) restoreTerminal(fd int) {
+	syscall.Write(fd, []byte(disableMouse+disableBracketedPaste))
+
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, e.terminal.termios); err != nil {
+		panic(err)
+	}
+}
+
+// waitInput waits until fd has input to read, and returns false if timeout passes first.
+func waitInput(fd int, timeout time.Duration) bool {
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+
+	for {
+		n, err := unix.Poll(fds, int(timeout/time.Millisecond))
+		if err == unix.EINTR {
+			continue
+		}
+		// Let the next read report an error.
+		return err != nil || n > 0
+	}
+}
+
+func getWindowSize(fd int) (int, int) {
+	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+	if err != nil {
+		panic(err)
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// newTerminal puts the terminal into raw mode, bracketed paste mode and mouse reporting
+// until restoreTerminal.
+func newTerminal(fd int) *Terminal {
+	t := &Terminal{termios: makeRaw(fd)}
+	syscall.Write(fd, []byte(enableBracketedPaste+enableMouse))
+	return t
+}
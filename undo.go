@@ -0,0 +1,233 @@
+package main
+
+import (
+	"unicode"
+)
+
+// Undo/Redo
+//
+// Every mutation of the rows goes through insertRune, deleteRune, insertRow,
+// deleteRow or replaceRune, and each of them records an editOp. The editOps
+// recorded while handling keys are grouped into an undoStep, which is undone
+// and redone at once.
+
+type opKind int
+
+const (
+	opInsertRune opKind = iota + 1
+	opDeleteRune
+	opInsertRow
+	opDeleteRow
+	opReplaceRow
+)
+
+type editOp struct {
+	kind  opKind
+	row   int
+	col   int
+	runes []rune // inserted or deleted runes
+	prev  []rune // runes before opReplaceRow
+}
+
+type undoStep struct {
+	ops []*editOp
+
+	// The cursor positions (in the buffer) before and after the step.
+	beforeRow, beforeCol int
+	afterRow, afterCol   int
+
+	typing bool
+}
+
+type History struct {
+	undoStack []*undoStep
+	redoStack []*undoStep
+	current   *undoStep
+	typing    bool // whether the next step is made by typing
+	grouping  bool // see beginGroup
+	replaying bool
+
+	// The step at the top of undoStack when the buffer was saved.
+	saved *undoStep
+}
+
+func (h *History) record(op *editOp, row, col int) {
+	if h.replaying {
+		return
+	}
+
+	if h.current == nil {
+		h.current = &undoStep{
+			beforeRow: row,
+			beforeCol: col,
+			typing:    h.typing,
+		}
+	}
+
+	h.current.ops = append(h.current.ops, op)
+	h.redoStack = nil
+}
+
+// commit closes the current step. row and col are the cursor position after the step.
+func (h *History) commit(row, col int) {
+	if h.current == nil {
+		return
+	}
+
+	if len(h.current.ops) == 0 {
+		h.current = nil
+		return
+	}
+
+	h.current.afterRow, h.current.afterCol = row, col
+	h.undoStack = append(h.undoStack, h.current)
+	h.current = nil
+}
+
+// checkpoint closes the current step unless both it and the next key are typing,
+// so that a run of typed runes is undone at once.
+func (h *History) checkpoint(typing bool, row, col int) {
+	if h.grouping {
+		return
+	}
+
+	if h.current != nil && h.current.typing && typing {
+		return
+	}
+
+	h.commit(row, col)
+	h.typing = typing
+}
+
+// beginGroup starts a step which lasts over several keys until endGroup is called.
+func (h *History) beginGroup(row, col int) {
+	h.commit(row, col)
+	h.current = &undoStep{
+		beforeRow: row,
+		beforeCol: col,
+	}
+	h.grouping = true
+}
+
+func (h *History) endGroup(row, col int) {
+	h.grouping = false
+	h.commit(row, col)
+}
+
+func (h *History) top() *undoStep {
+	if len(h.undoStack) == 0 {
+		return nil
+	}
+	return h.undoStack[len(h.undoStack)-1]
+}
+
+// markSaved remembers the current state as saved. row and col are the cursor position.
+func (h *History) markSaved(row, col int) {
+	h.commit(row, col)
+	h.saved = h.top()
+}
+
+// modified reports whether the steps done since markSaved (or the start) remain.
+func (h *History) modified() bool {
+	if h.current != nil && len(h.current.ops) > 0 {
+		return true
+	}
+	return h.top() != h.saved
+}
+
+func (h *History) popUndo() *undoStep {
+	if len(h.undoStack) == 0 {
+		return nil
+	}
+
+	step := h.undoStack[len(h.undoStack)-1]
+	h.undoStack = h.undoStack[:len(h.undoStack)-1]
+	h.redoStack = append(h.redoStack, step)
+	return step
+}
+
+func (h *History) popRedo() *undoStep {
+	if len(h.redoStack) == 0 {
+		return nil
+	}
+
+	step := h.redoStack[len(h.redoStack)-1]
+	h.redoStack = h.redoStack[:len(h.redoStack)-1]
+	h.undoStack = append(h.undoStack, step)
+	return step
+}
+
+// isTypingKey reports whether interpretKey inserts r as it is.
+func isTypingKey(r rune) bool {
+	return r >= 0x20 && r != BackSpace && r <= unicode.MaxRune
+}
+
+func (e *Editor) recordEdit(op *editOp) {
+	e.history.record(op, e.crow+e.scroolrow, e.ccol)
+}
+
+func (e *Editor) undo() {
+	e.history.commit(e.crow+e.scroolrow, e.ccol)
+
+	step := e.history.popUndo()
+	if step == nil {
+		return
+	}
+
+	e.history.replaying = true
+	for i := len(step.ops) - 1; i >= 0; i-- {
+		e.revertEdit(step.ops[i])
+	}
+	e.history.replaying = false
+
+	e.refreshAllRows()
+	e.jumpTo(step.beforeRow, step.beforeCol)
+}
+
+func (e *Editor) redo() {
+	e.history.commit(e.crow+e.scroolrow, e.ccol)
+
+	step := e.history.popRedo()
+	if step == nil {
+		return
+	}
+
+	e.history.replaying = true
+	for _, op := range step.ops {
+		e.applyEdit(op)
+	}
+	e.history.replaying = false
+
+	e.refreshAllRows()
+	e.jumpTo(step.afterRow, step.afterCol)
+}
+
+func (e *Editor) applyEdit(op *editOp) {
+	switch op.kind {
+	case opInsertRune:
+		e.insertRune(op.row, op.col, op.runes[0])
+	case opDeleteRune:
+		e.deleteRune(op.row, op.col)
+	case opInsertRow:
+		e.insertRow(op.row, op.runes)
+	case opDeleteRow:
+		e.deleteRow(op.row)
+	case opReplaceRow:
+		e.replaceRune(op.row, op.runes)
+	}
+}
+
+func (e *Editor) revertEdit(op *editOp) {
+	switch op.kind {
+	case opInsertRune:
+		e.deleteRune(op.row, op.col)
+	case opDeleteRune:
+		e.insertRune(op.row, op.col, op.runes[0])
+	case opInsertRow:
+		e.deleteRow(op.row)
+	case opDeleteRow:
+		e.insertRow(op.row, op.runes)
+	case opReplaceRow:
+		e.replaceRune(op.row, op.prev)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHistory_Typing(t *testing.T) {
+	h := &History{}
+
+	for i, r := range "abc" {
+		h.checkpoint(isTypingKey(r), 0, i)
+		h.record(&editOp{kind: opInsertRune, col: i, runes: []rune{r}}, 0, i)
+	}
+
+	h.checkpoint(isTypingKey(Enter), 0, 3)
+	h.record(&editOp{kind: opInsertRow, row: 1}, 0, 3)
+	h.checkpoint(isTypingKey(ControlZ), 1, 0)
+
+	assert.Equal(t, 2, len(h.undoStack))
+	assert.Equal(t, 3, len(h.undoStack[0].ops))
+	assert.Equal(t, 0, h.undoStack[0].beforeCol)
+	assert.Equal(t, 3, h.undoStack[0].afterCol)
+	assert.Equal(t, 1, len(h.undoStack[1].ops))
+	assert.Equal(t, 1, h.undoStack[1].afterRow)
+}
+
+func TestHistory_UndoRedo(t *testing.T) {
+	h := &History{}
+
+	h.checkpoint(false, 0, 0)
+	h.record(&editOp{kind: opDeleteRune}, 0, 0)
+	h.commit(0, 0)
+
+	step := h.popUndo()
+	assert.NotNil(t, step)
+	assert.Nil(t, h.popUndo())
+
+	assert.Equal(t, step, h.popRedo())
+	assert.Nil(t, h.popRedo())
+
+	// A new edit discards the redo history.
+	h.popUndo()
+	h.record(&editOp{kind: opInsertRune}, 0, 0)
+	assert.Nil(t, h.popRedo())
+}
+
+func TestHistory_Replaying(t *testing.T) {
+	h := &History{replaying: true}
+	h.record(&editOp{kind: opInsertRune}, 0, 0)
+	h.commit(0, 0)
+	assert.Equal(t, 0, len(h.undoStack))
+}
+
+func TestDeleteForward_Undo(t *testing.T) {
+	e := makeEditor("ab\ncd\n")
+	attachScreen(e, 20, 6)
+	cursor := func() (int, int) { return e.crow + e.scroolrow, e.ccol }
+
+	e.setColPos(1)
+	e.interpretKey(Delete)
+	assert.Equal(t, "a\n", e.rows[0].chars.RunesString())
+	e.interpretKey(ControlZ)
+	assert.Equal(t, "ab\n", e.rows[0].chars.RunesString())
+	row, col := cursor()
+	assert.Equal(t, 0, row)
+	assert.Equal(t, 1, col)
+
+	// Joining the next row.
+	e.setColPos(2)
+	e.interpretKey(Delete)
+	assert.Equal(t, "abcd\n", e.rows[0].chars.RunesString())
+	row, col = cursor()
+	assert.Equal(t, 0, row)
+	assert.Equal(t, 2, col)
+	e.interpretKey(ControlZ)
+	assert.Equal(t, "ab\n", e.rows[0].chars.RunesString())
+	assert.Equal(t, "cd\n", e.rows[1].chars.RunesString())
+	row, col = cursor()
+	assert.Equal(t, 0, row)
+	assert.Equal(t, 2, col)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"unicode"
+)
+
+// Display width of runes and grapheme clusters.
+//
+// A column in a Row (e.g. Editor.ccol) is a rune index into its GapTable, while a
+// column on the screen is a terminal cell. The functions below map one to the other.
+// Grapheme clusters are a simplified version of UAX #29: a base rune followed by
+// combining marks, variation selectors, emoji modifiers and ZWJ sequences, and
+// pairs of regional indicators (flags).
+
+type runeRange struct {
+	lo, hi rune
+}
+
+// East Asian Wide (W) and Fullwidth (F) characters, including emoji presentation.
+var wideRanges = []runeRange{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x23F0, 0x23F0}, {0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE},
+	{0x26D4, 0x26D4}, {0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5},
+	{0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF},
+	{0xA960, 0xA97F}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x16FE0, 0x16FE4},
+	{0x17000, 0x18AFF}, {0x1B000, 0x1B2FF}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F251}, {0x1F260, 0x1F265},
+	{0x1F300, 0x1F320}, {0x1F32D, 0x1F335}, {0x1F337, 0x1F37C}, {0x1F37E, 0x1F393},
+	{0x1F3A0, 0x1F3CA}, {0x1F3CF, 0x1F3D3}, {0x1F3E0, 0x1F3F0}, {0x1F3F4, 0x1F3F4},
+	{0x1F3F8, 0x1F43E}, {0x1F440, 0x1F440}, {0x1F442, 0x1F4FC}, {0x1F4FF, 0x1F53D},
+	{0x1F54B, 0x1F54E}, {0x1F550, 0x1F567}, {0x1F57A, 0x1F57A}, {0x1F595, 0x1F596},
+	{0x1F5A4, 0x1F5A4}, {0x1F5FB, 0x1F64F}, {0x1F680, 0x1F6C5}, {0x1F6CC, 0x1F6CC},
+	{0x1F6D0, 0x1F6D2}, {0x1F6D5, 0x1F6D7}, {0x1F6EB, 0x1F6EC}, {0x1F6F4, 0x1F6FC},
+	{0x1F7E0, 0x1F7EB}, {0x1F90C, 0x1F93A}, {0x1F93C, 0x1F945}, {0x1F947, 0x1F9FF},
+	{0x1FA70, 0x1FAFF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+const (
+	zeroWidthJoiner   = 0x200D
+	variationSelector = 0xFE0F // emoji presentation
+)
+
+// A tab advances to the next multiple of tabWidth.
+const tabWidth = 4
+
+func inRanges(r rune, ranges []runeRange) bool {
+	lo, hi := 0, len(ranges)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch {
+		case r < ranges[mid].lo:
+			hi = mid
+		case r > ranges[mid].hi:
+			lo = mid + 1
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+func isControlRune(r rune) bool {
+	return r < 0x20 || (r >= 0x7F && r < 0xA0)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isExtendingRune reports whether r continues the grapheme cluster before it.
+func isExtendingRune(r rune) bool {
+	switch {
+	case r == zeroWidthJoiner:
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F: // variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // emoji modifiers
+		return true
+	case r >= 0x1160 && r <= 0x11FF: // hangul jungseong, jongseong
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // tags
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc)
+}
+
+// runeWidth returns the number of cells r occupies on its own.
+func runeWidth(r rune) int {
+	switch {
+	case isRawByte(r):
+		// Rendered as U+FFFD.
+		return 1
+	case isControlRune(r):
+		return 0
+	case isExtendingRune(r) || unicode.Is(unicode.Cf, r):
+		return 0
+	case inRanges(r, wideRanges):
+		return 2
+	}
+	return 1
+}
+
+// clusterEnd returns the index just after the grapheme cluster starting at runes[i].
+func clusterEnd(runes []rune, i int) int {
+	if i >= len(runes) {
+		return len(runes)
+	}
+
+	first := runes[i]
+	i += 1
+
+	if isControlRune(first) {
+		if first == '\r' && i < len(runes) && runes[i] == '\n' {
+			return i + 1
+		}
+		return i
+	}
+
+	if isRegionalIndicator(first) && i < len(runes) && isRegionalIndicator(runes[i]) {
+		i += 1
+	}
+
+	for i < len(runes) {
+		r := runes[i]
+		if isControlRune(r) {
+			break
+		}
+		if runes[i-1] == zeroWidthJoiner || isExtendingRune(r) {
+			i += 1
+			continue
+		}
+		break
+	}
+
+	return i
+}
+
+// clusterStart returns the index of the grapheme cluster which ends just before runes[i].
+func clusterStart(runes []rune, i int) int {
+	start := 0
+	for j := 0; j < i && j < len(runes); {
+		start = j
+		j = clusterEnd(runes, j)
+	}
+	return start
+}
+
+// clusterWidth returns the number of cells a single grapheme cluster occupies.
+func clusterWidth(cluster []rune) int {
+	if len(cluster) == 0 {
+		return 0
+	}
+
+	w := runeWidth(cluster[0])
+	if w == 0 && unicode.In(cluster[0], unicode.Mn, unicode.Me) {
+		// A combining mark without a base is drawn on its own.
+		w = 1
+	}
+
+	// Spacing marks take a cell of their own in most terminals.
+	for _, r := range cluster[1:] {
+		if unicode.Is(unicode.Mc, r) {
+			w += 1
+		}
+	}
+
+	if w == 1 {
+		if len(cluster) >= 2 && isRegionalIndicator(cluster[0]) && isRegionalIndicator(cluster[1]) {
+			return 2
+		}
+		for _, r := range cluster[1:] {
+			if r == variationSelector {
+				return 2
+			}
+		}
+	}
+
+	return w
+}
+
+// cellWidth returns the number of cells a grapheme cluster occupies when drawn at column x.
+// It differs from clusterWidth only for a tab.
+func cellWidth(cluster []rune, x int) int {
+	if len(cluster) == 1 && cluster[0] == '\t' {
+		return tabWidth - x%tabWidth
+	}
+	return clusterWidth(cluster)
+}
+
+// runesWidth returns the number of cells runes occupy.
+func runesWidth(runes []rune) int {
+	w := 0
+	for i := 0; i < len(runes); {
+		end := clusterEnd(runes, i)
+		w += cellWidth(runes[i:end], w)
+		i = end
+	}
+	return w
+}
+
+func stringWidth(s string) int {
+	return runesWidth([]rune(s))
+}
+
+// truncateString cuts s so that it fits in width cells.
+func truncateString(s string, width int) string {
+	runes := []rune(s)
+	return string(runes[:indexOf(runes, width)])
+}
+
+// columnOf returns the screen column at which runes[index] is drawn.
+func columnOf(runes []rune, index int) int {
+	if index > len(runes) {
+		index = len(runes)
+	}
+	return runesWidth(runes[:index])
+}
+
+// indexOf returns the index of the grapheme cluster covering the screen column col.
+// If col is beyond the end of runes, len(runes) is returned.
+func indexOf(runes []rune, col int) int {
+	x := 0
+	for i := 0; i < len(runes); {
+		end := clusterEnd(runes, i)
+		x += cellWidth(runes[i:end], x)
+		if x > col {
+			return i
+		}
+		i = end
+	}
+	return len(runes)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRuneWidth(t *testing.T) {
+	assert.Equal(t, 1, runeWidth('a'))
+	assert.Equal(t, 2, runeWidth('あ'))
+	assert.Equal(t, 2, runeWidth('漢'))
+	assert.Equal(t, 2, runeWidth('Ａ'))
+	assert.Equal(t, 2, runeWidth('😀'))
+	assert.Equal(t, 0, runeWidth('\u0301'))
+	assert.Equal(t, 0, runeWidth('\n'))
+	assert.Equal(t, 1, runeWidth(rawByteMin))
+}
+
+func TestClusterEnd(t *testing.T) {
+	// e + combining acute accent
+	assert.Equal(t, 2, clusterEnd([]rune("e\u0301x"), 0))
+	// woman + ZWJ + laptop
+	assert.Equal(t, 3, clusterEnd([]rune("👩\u200d💻"), 0))
+	// thumbs up + skin tone modifier
+	assert.Equal(t, 2, clusterEnd([]rune("👍🏽!"), 0))
+	// flag of Japan
+	assert.Equal(t, 2, clusterEnd([]rune("🇯🇵🇯🇵"), 0))
+	assert.Equal(t, 1, clusterEnd([]rune("ab"), 0))
+	assert.Equal(t, 2, clusterEnd([]rune("ab"), 5))
+}
+
+func TestClusterStart(t *testing.T) {
+	runes := []rune("ae\u0301b")
+	assert.Equal(t, 0, clusterStart(runes, 1))
+	assert.Equal(t, 1, clusterStart(runes, 3))
+	assert.Equal(t, 3, clusterStart(runes, 4))
+}
+
+func TestRunesWidth(t *testing.T) {
+	assert.Equal(t, 5, runesWidth([]rune("hello")))
+	assert.Equal(t, 10, runesWidth([]rune("こんにちは")))
+	assert.Equal(t, 4, runesWidth([]rune("cafe\u0301")))
+	assert.Equal(t, 2, runesWidth([]rune("👩\u200d💻")))
+	assert.Equal(t, 2, runesWidth([]rune("🇯🇵")))
+	assert.Equal(t, 2, runesWidth([]rune("❤\ufe0f")))
+	assert.Equal(t, 2, runesWidth([]rune("ab\n")))
+
+	// A combining mark without a base takes a cell, but format characters don't.
+	assert.Equal(t, 1, runesWidth([]rune("\u0301")))
+	assert.Equal(t, 2, runesWidth([]rune("\ufeffab")))
+	assert.Equal(t, 2, runesWidth([]rune("a\u200bb")))
+}
+
+func TestColumnOf_IndexOf(t *testing.T) {
+	runes := []rune("aあe\u0301b")
+
+	assert.Equal(t, 0, columnOf(runes, 0))
+	assert.Equal(t, 1, columnOf(runes, 1))
+	assert.Equal(t, 3, columnOf(runes, 2))
+	assert.Equal(t, 4, columnOf(runes, 4))
+	assert.Equal(t, 5, columnOf(runes, 5))
+
+	assert.Equal(t, 0, indexOf(runes, 0))
+	assert.Equal(t, 1, indexOf(runes, 1))
+	assert.Equal(t, 1, indexOf(runes, 2)) // the right half of あ
+	assert.Equal(t, 2, indexOf(runes, 3))
+	assert.Equal(t, 4, indexOf(runes, 4))
+	assert.Equal(t, 5, indexOf(runes, 10))
+}
+
+func TestRow_Columns(t *testing.T) {
+	gt := NewGapTable(128)
+	for _, r := range "日本語\n" {
+		gt.AppendRune(r)
+	}
+	row := &Row{chars: gt}
+
+	assert.Equal(t, 6, row.columnAt(3))
+	assert.Equal(t, 6, row.columnAt(4))
+	assert.Equal(t, 2, row.indexAt(5))
+	assert.Equal(t, 3, row.indexAt(6))
+	assert.Equal(t, 2, row.nextCluster(1))
+	assert.Equal(t, 1, row.prevCluster(2))
+}
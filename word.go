@@ -0,0 +1,93 @@
+package main
+
+import (
+	"unicode"
+)
+
+// Word motion
+//
+// A word is a run of letters, digits and underscores, as in Go identifiers.
+// Everything else, including the end of a row, separates words, so the motion
+// moves on to the next or previous row when the current one has no more words.
+// Killed words go to the kill ring, and consecutive kills are joined.
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// nextWordEnd returns the end of the word at or after (row, col).
+func (e *Editor) nextWordEnd(row, col int) (int, int) {
+	inWord := false
+	for {
+		runes := e.rows[row].visibleRunes()
+		for col < len(runes) {
+			w := isWordRune(runes[col])
+			if inWord && !w {
+				return row, col
+			}
+			inWord = inWord || w
+			col = clusterEnd(runes, col)
+		}
+
+		if inWord || row+1 >= e.n {
+			return row, col
+		}
+		row, col = row+1, 0
+	}
+}
+
+// prevWordStart returns the start of the word at or before (row, col).
+func (e *Editor) prevWordStart(row, col int) (int, int) {
+	inWord := false
+	for {
+		runes := e.rows[row].visibleRunes()
+		for col > 0 {
+			prev := clusterStart(runes, col)
+			w := isWordRune(runes[prev])
+			if inWord && !w {
+				return row, col
+			}
+			inWord = inWord || w
+			col = prev
+		}
+
+		if inWord || row == 0 {
+			return row, col
+		}
+		row, col = row-1, e.rows[row-1].visibleLen()
+	}
+}
+
+func (e *Editor) forwardWord() {
+	e.jumpTo(e.nextWordEnd(e.crow+e.scroolrow, e.ccol))
+}
+
+func (e *Editor) backwardWord() {
+	e.jumpTo(e.prevWordStart(e.crow+e.scroolrow, e.ccol))
+}
+
+// killWord kills up to the end of the word.
+func (e *Editor) killWord() {
+	row := e.crow + e.scroolrow
+	endRow, endCol := e.nextWordEnd(row, e.ccol)
+	if endRow == row && endCol == e.ccol {
+		return
+	}
+
+	e.kill(e.deleteText(row, e.ccol, endRow, endCol), false)
+	e.redrawAllRows()
+	e.setColPos(e.ccol)
+}
+
+// backwardKillWord kills back to the start of the word.
+func (e *Editor) backwardKillWord() {
+	row := e.crow + e.scroolrow
+	startRow, startCol := e.prevWordStart(row, e.ccol)
+	if startRow == row && startCol == e.ccol {
+		return
+	}
+
+	e.kill(e.deleteText(startRow, startCol, row, e.ccol), true)
+	e.redrawAllRows()
+	e.jumpTo(startRow, startCol)
+}
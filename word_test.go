@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNextWordEnd(t *testing.T) {
+	e := makeEditor("foo_bar(x1, 変数)\n\n  baz\n")
+
+	row, col := e.nextWordEnd(0, 0)
+	assert.Equal(t, []int{0, 7}, []int{row, col})
+	row, col = e.nextWordEnd(0, 7)
+	assert.Equal(t, []int{0, 10}, []int{row, col})
+	row, col = e.nextWordEnd(0, 10)
+	assert.Equal(t, []int{0, 14}, []int{row, col})
+
+	// The motion crosses rows, skipping the empty one.
+	row, col = e.nextWordEnd(0, 14)
+	assert.Equal(t, []int{2, 5}, []int{row, col})
+	row, col = e.nextWordEnd(2, 5)
+	assert.Equal(t, []int{3, 0}, []int{row, col})
+}
+
+func TestPrevWordStart(t *testing.T) {
+	e := makeEditor("foo_bar(x1, 変数)\n\n  baz\n")
+
+	row, col := e.prevWordStart(2, 4)
+	assert.Equal(t, []int{2, 2}, []int{row, col})
+	row, col = e.prevWordStart(2, 2)
+	assert.Equal(t, []int{0, 12}, []int{row, col})
+	row, col = e.prevWordStart(0, 12)
+	assert.Equal(t, []int{0, 8}, []int{row, col})
+	row, col = e.prevWordStart(0, 8)
+	assert.Equal(t, []int{0, 0}, []int{row, col})
+	row, col = e.prevWordStart(0, 0)
+	assert.Equal(t, []int{0, 0}, []int{row, col})
+}
+
+func TestKillWord(t *testing.T) {
+	e := makeEditor("foo bar baz\n")
+	attachScreen(e, 20, 5)
+
+	e.setColPos(4)
+	e.interpretKey(Alt + 'd')
+	e.interpretKey(Alt + 'd')
+	assert.Equal(t, "foo \n", string(e.rows[0].chars.Runes()))
+	assert.Equal(t, "bar baz", string(e.killRing.newest()))
+
+	e.interpretKey(Alt + BackSpace)
+	assert.Equal(t, "\n", string(e.rows[0].chars.Runes()))
+	assert.Equal(t, 0, e.ccol)
+	assert.Equal(t, "foo bar baz", string(e.killRing.newest()))
+	assert.Equal(t, 1, len(e.killRing.entries))
+}
@@ -0,0 +1,229 @@
+package main
+
+// Soft wrap
+//
+// Alt+L toggles soft wrap, which draws a row longer than the screen on several
+// visual lines instead of scrolling horizontally. scroolrow and crow still count
+// rows of the buffer, so only drawing, placing the cursor on the screen, and
+// moving up and down by visual lines need to know about the wrapping.
+
+// wrapRow returns the indices where the visual lines of runes start, when they are
+// wrapped at width. A row filling the last line has an empty line after it for
+// the cursor at its end.
+func wrapRow(runes []rune, width int) []int {
+	starts := []int{0}
+	x := 0
+	for i := 0; i < len(runes); {
+		end := clusterEnd(runes, i)
+		w := cellWidth(runes[i:end], x)
+		if x > 0 && x+w > width {
+			starts = append(starts, i)
+			x = 0
+			w = cellWidth(runes[i:end], 0)
+		}
+		x += w
+		i = end
+	}
+
+	if x >= width {
+		starts = append(starts, len(runes))
+	}
+	return starts
+}
+
+// lineOf returns the visual line which has the index col.
+func lineOf(starts []int, col int) int {
+	line := 0
+	for line+1 < len(starts) && starts[line+1] <= col {
+		line++
+	}
+	return line
+}
+
+// indexInLine returns the index at the column x of the visual line, staying in the line.
+func indexInLine(runes []rune, starts []int, line, x int) int {
+	end := len(runes)
+	if line+1 < len(starts) {
+		end = starts[line+1]
+	}
+
+	i := starts[line] + indexOf(runes[starts[line]:end], x)
+	if i == end && line+1 < len(starts) {
+		i = clusterStart(runes, end)
+	}
+	return i
+}
+
+func (e *Editor) wrapStarts(row int) []int {
+	return wrapRow(e.rows[row].visibleRunes(), e.textWidth())
+}
+
+// rowHeight returns the number of lines the row takes on the screen.
+func (e *Editor) rowHeight(row int) int {
+	if !e.wrap || row >= e.n {
+		return 1
+	}
+	return len(e.wrapStarts(row))
+}
+
+// linesBetween returns the number of lines the rows from from to to (exclusive) take.
+func (e *Editor) linesBetween(from, to int) int {
+	lines := 0
+	for row := from; row < to; row++ {
+		lines += e.rowHeight(row)
+	}
+	return lines
+}
+
+// shownRows returns the number of rows from scroolrow which start on the screen.
+func (e *Editor) shownRows() int {
+	if !e.wrap {
+		return e.textHeight()
+	}
+
+	n := 0
+	for line := 0; line < e.textHeight(); n++ {
+		line += e.rowHeight(e.scroolrow + n)
+	}
+	return n
+}
+
+// lastScroolrow returns the largest scroolrow which shows the end of the buffer.
+func (e *Editor) lastScroolrow() int {
+	if !e.wrap {
+		if e.n < e.textHeight() {
+			return 0
+		}
+		return e.n - e.textHeight()
+	}
+
+	row, lines := e.n, 0
+	for row > 0 && lines+e.rowHeight(row-1) <= e.textHeight() {
+		row--
+		lines += e.rowHeight(row)
+	}
+	if row == e.n && row > 0 {
+		// The last row is taller than the screen.
+		row--
+	}
+	return row
+}
+
+// drawWrappedRows draws the rows from scroolrow on as many lines as they take.
+func (e *Editor) drawWrappedRows() {
+	width := e.textWidth()
+	line := 0
+	for row := e.scroolrow; line < e.textHeight(); row++ {
+		if row >= e.n {
+			clearRow(e.screen, line, BgDefault)
+			line++
+			continue
+		}
+
+		r := e.rows[row]
+		runes := r.chars.Runes()
+		colors := e.rowColors(r, row)
+		starts := wrapRow(r.visibleRunes(), width)
+		for i, start := range starts {
+			if line >= e.textHeight() {
+				break
+			}
+
+			end := len(runes)
+			if i+1 < len(starts) {
+				end = starts[i+1]
+			}
+
+			var lineColors []color
+			if start < len(colors) {
+				lineColors = colors[start:]
+			}
+
+			clearRow(e.screen, line, BgDefault)
+			drawRunes(e.screen, line, e.gutterWidth(), runes[start:end], lineColors, BgDefault)
+			line++
+		}
+	}
+}
+
+// cursorLine returns the visual line of the cursor in its row, and the column in the line.
+func (e *Editor) cursorLine() (line, x int) {
+	runes := e.currentRow().visibleRunes()
+	col := e.ccol
+	if col > len(runes) {
+		// The cursor is moving to a shorter row.
+		col = len(runes)
+	}
+
+	starts := wrapRow(runes, e.textWidth())
+	line = lineOf(starts, col)
+	return line, runesWidth(runes[starts[line]:col])
+}
+
+// wrappedCursor returns the position of the cursor on the screen.
+func (e *Editor) wrappedCursor() (int, int) {
+	line, x := e.cursorLine()
+	line += e.linesBetween(e.scroolrow, e.crow+e.scroolrow)
+	if line >= e.textHeight() {
+		// Only the top of a row taller than the screen is shown.
+		line = e.textHeight() - 1
+	}
+	return line, x
+}
+
+// scrollToCursorLine scrolls down until the visual line of the cursor is on the screen.
+func (e *Editor) scrollToCursorLine() {
+	row := e.crow + e.scroolrow
+	line, _ := e.cursorLine()
+	if e.linesBetween(e.scroolrow, row)+line < e.textHeight() {
+		return
+	}
+
+	for e.scroolrow < row && e.linesBetween(e.scroolrow, row)+line >= e.textHeight() {
+		e.scroolrow++
+	}
+	e.crow = row - e.scroolrow
+	e.drawWrappedRows()
+}
+
+// moveLine moves the cursor down (dir is 1) or up (dir is -1) by a row, or by
+// a visual line when wrapping, keeping the screen column.
+func (e *Editor) moveLine(dir int) {
+	if !e.wrap {
+		e.setRowKeepColumn(e.crow + dir)
+		return
+	}
+
+	row := e.crow + e.scroolrow
+	line, x := e.cursorLine()
+	starts := e.wrapStarts(row)
+
+	line += dir
+	if line < 0 || line >= len(starts) {
+		if row+dir < 0 || row+dir >= e.n {
+			return
+		}
+
+		e.setRowPos(e.crow + dir)
+		starts = e.wrapStarts(row + dir)
+		line = 0
+		if dir < 0 {
+			line = len(starts) - 1
+		}
+	}
+
+	e.setColPos(indexInLine(e.currentRow().visibleRunes(), starts, line, x))
+}
+
+func (e *Editor) toggleWrap() {
+	e.wrap = !e.wrap
+	e.scroolcol = 0
+	e.redrawAllRows()
+	e.setColPos(e.ccol)
+
+	if e.wrap {
+		e.setMessage("Soft wrap on")
+	} else {
+		e.setMessage("Soft wrap off")
+	}
+}
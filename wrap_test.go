@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWrapRow(t *testing.T) {
+	assert.Equal(t, []int{0}, wrapRow([]rune(""), 4))
+	assert.Equal(t, []int{0}, wrapRow([]rune("abc"), 4))
+	assert.Equal(t, []int{0, 4}, wrapRow([]rune("abcd"), 4))
+	assert.Equal(t, []int{0, 4, 8}, wrapRow([]rune("abcdefghij"), 4))
+
+	// A wide character doesn't fit at the end of a line.
+	assert.Equal(t, []int{0, 2, 4}, wrapRow([]rune("aあいう"), 4))
+	assert.Equal(t, []int{0, 3}, wrapRow([]rune("abcあ"), 4))
+}
+
+func TestMoveLine_Wrap(t *testing.T) {
+	e := makeEditor("0123456789\nab\n")
+	attachScreen(e, 4, 8)
+	e.toggleWrap()
+
+	e.setColPos(1)
+	e.moveLine(1)
+	assert.Equal(t, 0, e.crow)
+	assert.Equal(t, 5, e.ccol)
+	row, col := e.screen.(*MemoryScreen).Cursor()
+	assert.Equal(t, []int{1, 1}, []int{row, col})
+
+	e.moveLine(1)
+	e.moveLine(1)
+	assert.Equal(t, 1, e.crow)
+	assert.Equal(t, 1, e.ccol)
+	row, col = e.screen.(*MemoryScreen).Cursor()
+	assert.Equal(t, []int{3, 1}, []int{row, col})
+
+	e.moveLine(-1)
+	assert.Equal(t, 0, e.crow)
+	assert.Equal(t, 9, e.ccol)
+}